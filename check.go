@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/perlmonger42/tdop/scan"
+)
+
+// check implements `tdop check [-j workers] [-asi] [-globals] [-warn checks]
+// pattern...`, which parses JavaScript files and reports their syntax errors,
+// and the warnings of the comma-separated checks. A pattern is a file, a
+// directory (meaning the .js files in it), or a directory followed by "/..."
+// (meaning the .js files anywhere beneath it). It returns the exit status.
+func check(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	workers := flags.Int("j", 0, "number of files to parse at once (default GOMAXPROCS)")
+	asi := flags.Bool("asi", false, "insert semicolons automatically")
+	globals := flags.Bool("globals", false, "accept undeclared names as globals")
+	warn := flags.String("warn", "", "report the warnings of these comma-separated checks: undeclared, unused, unused-assignment, shadow")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	patterns := flags.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+	paths, err := expand(patterns)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	options := []scan.Option{}
+	if *asi {
+		options = append(options, scan.AutomaticSemicolons())
+	}
+	if *globals {
+		options = append(options, scan.UndeclaredNames())
+	}
+	if *warn != "" {
+		checks := []scan.Check{}
+		for _, c := range strings.Split(*warn, ",") {
+			switch scan.Check(c) {
+			case scan.CheckUndeclared, scan.CheckUnused, scan.CheckUnusedAssignment, scan.CheckShadow:
+				checks = append(checks, scan.Check(c))
+			default:
+				fmt.Fprintf(os.Stderr, "unknown check %q\n", c)
+				return 2
+			}
+		}
+		options = append(options, scan.Checks(checks...))
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	batch, err := scan.NewParser(options...).ParseFiles(ctx, paths, *workers)
+	status := 0
+	for _, f := range batch.Files {
+		if f.Err != nil {
+			fmt.Println(f.Err)
+			status = 1
+		}
+		if f.Module != nil {
+			for _, w := range f.Module.Warnings {
+				fmt.Printf("%s:%s\n", f.Path, w)
+			}
+		}
+	}
+	fmt.Fprintf(os.Stderr, "checked %s\n", batch.Throughput())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return status
+}
+
+// expand returns the paths of the files that patterns name, sorted and
+// without duplicates.
+func expand(patterns []string) ([]string, error) {
+	seen := map[string]bool{}
+	for _, pattern := range patterns {
+		root, recursive := pattern, false
+		if pattern == "..." || strings.HasSuffix(pattern, "/...") {
+			root, recursive = strings.TrimSuffix(strings.TrimSuffix(pattern, "..."), "/"), true
+			if root == "" {
+				root = "."
+			}
+		}
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			seen[filepath.Clean(root)] = true
+			continue
+		}
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() && path != root && !recursive {
+				return filepath.SkipDir
+			}
+			if !d.IsDir() && filepath.Ext(path) == ".js" {
+				seen[path] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	paths := make([]string, 0, len(seen))
+	for path := range seen {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/perlmonger42/tdop/scan"
+)
+
+// docs implements `tdop docs [-format markdown|html] [-grammar file]`, which
+// writes a precedence chart and a grammar summary of the JavaScript grammar,
+// or of the grammar defined by a JSON file. It returns the exit status.
+func docs(args []string) int {
+	flags := flag.NewFlagSet("docs", flag.ContinueOnError)
+	format := flags.String("format", "markdown", "the output format: markdown or html")
+	grammar := flags.String("grammar", "", "document the grammar defined by this JSON file")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, "usage: tdop docs [-format markdown|html] [-grammar file]")
+		return 2
+	}
+
+	parser := scan.NewParser()
+	if *grammar != "" {
+		f, err := os.Open(*grammar)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer f.Close()
+		if parser, err = scan.NewParserFromGrammar(f); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	if err := parser.WriteDocs(os.Stdout, *format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
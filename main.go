@@ -30,6 +30,19 @@ func writer() *tabwriter.Writer {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "check" {
+		os.Exit(check(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "trace" {
+		os.Exit(trace(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "docs" {
+		os.Exit(docs(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(validate(os.Args[2:]))
+	}
+
 	w := writer()
 	for i, token := range scan.TokenizeString("Hello, world!\n") {
 		fmt.Fprintf(w, "%d:\t %s \t %q \t\n", i, token.TkType, token.TkValue)
@@ -0,0 +1,120 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// A Batch is the outcome of parsing many files with ParseFiles.
+type Batch struct {
+	Files    []FileResult // one for each path, in the order given
+	Parsed   int          // the number of files read and parsed
+	Bytes    int64        // the total size of the files parsed
+	Duration time.Duration
+}
+
+// A FileResult is the outcome of parsing one file of a Batch. Err is a
+// *LoadError for a syntax error, the error from reading the file, or the
+// error recovered from any other panic while parsing it.
+type FileResult struct {
+	Path   string
+	Tree   *Token
+	Module *Module
+	Err    error
+}
+
+// ParseFiles reads and parses the files at paths with up to workers
+// goroutines at once (or GOMAXPROCS goroutines, if workers is not positive).
+// If ctx is cancelled, it stops starting new files and returns what it has
+// done so far along with ctx.Err(); the files it didn't get to have no tree
+// and no error.
+func (p *Parser) ParseFiles(ctx context.Context, paths []string, workers int) (*Batch, error) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	start := time.Now()
+	b := &Batch{Files: make([]FileResult, len(paths))}
+	for i, path := range paths {
+		b.Files[i].Path = path
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				r := &b.Files[i]
+				source, err := os.ReadFile(r.Path)
+				if err != nil {
+					r.Err = err
+					continue
+				}
+				mu.Lock()
+				b.Parsed += 1
+				b.Bytes += int64(len(source))
+				mu.Unlock()
+				p.parseResult(r, string(source))
+			}
+		}()
+	}
+	var err error
+feed:
+	for i := range paths {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			err = ctx.Err()
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	b.Duration = time.Since(start)
+	return b, err
+}
+
+// parseResult parses source, the contents of the file of r, into r. A panic
+// other than a syntax error, such as one from a faulty denotation, becomes
+// the error of the file rather than ending the whole batch.
+func (p *Parser) parseResult(r *FileResult, source string) {
+	defer func() {
+		if e := recover(); e != nil {
+			r.Tree, r.Module = nil, nil
+			r.Err = fmt.Errorf("%s: internal error: %v", r.Path, e)
+		}
+	}()
+	r.Tree, r.Module, r.Err = parseFile(p, r.Path, source)
+}
+
+// Errors returns the errors of the files of b, in the order of the files.
+func (b *Batch) Errors() []error {
+	errors := []error{}
+	for _, r := range b.Files {
+		if r.Err != nil {
+			errors = append(errors, r.Err)
+		}
+	}
+	return errors
+}
+
+// Throughput describes how quickly the files of b were parsed. It counts
+// only the files that were parsed, not those that couldn't be read or that
+// a cancellation kept it from starting.
+func (b *Batch) Throughput() string {
+	seconds := b.Duration.Seconds()
+	if seconds <= 0 {
+		return fmt.Sprintf("%d files, %d bytes", b.Parsed, b.Bytes)
+	}
+	return fmt.Sprintf("%d files, %d bytes in %v (%.0f files/s, %.2f MB/s)",
+		b.Parsed, b.Bytes, b.Duration.Round(time.Millisecond),
+		float64(b.Parsed)/seconds, float64(b.Bytes)/seconds/1e6)
+}
@@ -0,0 +1,92 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseFiles(t *testing.T) {
+	dir := t.TempDir()
+	paths := []string{}
+	for i := 0; i < 40; i++ {
+		source := fmt.Sprintf("let a%d = %d;", i, i)
+		if i%7 == 3 {
+			source = fmt.Sprintf("let a = 1;\nlet a = %d;", i)
+		}
+		path := filepath.Join(dir, fmt.Sprintf("f%02d.js", i))
+		if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	paths = append(paths, filepath.Join(dir, "missing.js"))
+
+	batch, err := NewParser().ParseFiles(context.Background(), paths, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Files) != len(paths) || batch.Bytes == 0 {
+		t.Fatalf("expected %d results and some bytes; got %d and %d", len(paths), len(batch.Files), batch.Bytes)
+	}
+	for i, r := range batch.Files {
+		if r.Path != paths[i] {
+			t.Errorf("expected result %d to be for %s; got %s", i, paths[i], r.Path)
+		}
+	}
+	if r := batch.Files[0]; r.Err != nil || r.Tree.NdId != "=" {
+		t.Errorf("expected f00.js to parse; got %v, %v", r.Tree, r.Err)
+	}
+	errors := batch.Errors()
+	if len(errors) != 7 {
+		t.Fatalf("expected 7 errors; got %v", errors)
+	}
+	for i, e := range errors[:6] {
+		expected := fmt.Sprintf("f%02d.js:2:4: Already defined", 3+7*i)
+		if !strings.Contains(e.Error(), expected) {
+			t.Errorf("expected `%s`; got %v", expected, e)
+		}
+	}
+	if !os.IsNotExist(errors[6]) {
+		t.Errorf("expected the missing file to be reported; got %v", errors[6])
+	}
+	if s := batch.Throughput(); !strings.HasPrefix(s, "40 files, ") {
+		t.Errorf("expected a throughput report; got %s", s)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	batch, err = NewParser().ParseFiles(ctx, paths, 2)
+	if err != context.Canceled {
+		t.Errorf("expected the batch to be cancelled; got %v", err)
+	}
+	if len(batch.Files) != len(paths) || batch.Files[0].Tree != nil || len(batch.Errors()) != 0 {
+		t.Errorf("expected no files to be parsed after the cancellation")
+	}
+	if s := batch.Throughput(); !strings.HasPrefix(s, "0 files, 0 bytes") {
+		t.Errorf("expected no files in the throughput after the cancellation; got %s", s)
+	}
+
+	// A panic in a denotation fails only the file being parsed.
+	parser := NewParser()
+	parser.Prefix("boom", func(p *Parser, this *Token) *Token {
+		panic("a faulty denotation")
+	})
+	path := filepath.Join(dir, "boom.js")
+	if err := os.WriteFile(path, []byte("let b = boom;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	batch, err = parser.ParseFiles(context.Background(), []string{path, paths[0]}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := batch.Files[0].Err; e == nil || !strings.Contains(e.Error(), "boom.js: internal error: a faulty denotation") {
+		t.Errorf("expected the panic to be the error of boom.js; got %v", e)
+	}
+	if r := batch.Files[1]; r.Err != nil || r.Tree == nil {
+		t.Errorf("expected f00.js to parse; got %v", r.Err)
+	}
+}
@@ -0,0 +1,102 @@
+package scan
+
+import "sort"
+
+// CaptureAnalysis makes the parser work out what each function captures,
+// for compiling closures. It sets these fields:
+//
+//   - NdCaptures of a function node: its free variables, the declarations
+//     outside it of the names it uses (those used by the functions nested
+//     in it included), in the order they are declared;
+//   - NdLocals of a function node: its own declarations, its parameters
+//     included, in the order they are declared;
+//   - NdCaptured of a declaration: it is used by a function nested in the
+//     one that declares it, so it can't live on the stack;
+//   - NdReassigned of a captured declaration: it may be assigned after it
+//     is first captured. An assignment counts if it follows the capturing
+//     function literal, is in a nested function, or shares a loop with the
+//     literal; this errs on the side of reassignment.
+//
+// Names that are never declared are globals, and aren't captured.
+func CaptureAnalysis() Option {
+	return func(p *Parser) {
+		p.capture = true
+	}
+}
+
+// A closure is a function literal and the references it makes to names
+// declared outside it.
+type closure struct {
+	fn   *Token
+	loop *Token // the outermost loop around the literal, or nil
+	refs []*reference
+}
+
+// An assignment is the target of an assignment to a declared name.
+type assignment struct {
+	name    *Token
+	closure bool   // the assignment is in a function nested in the declaring one
+	loop    *Token // the outermost loop around the assignment, or nil
+}
+
+// popFunctionScope closes the scope of the function this. The references the
+// scope can't resolve are those to the function's free variables.
+func (p *Parser) popFunctionScope(this *Token) {
+	s := p.scope
+	free := p.popScope()
+	if !p.capture {
+		return
+	}
+	this.NdLocals = append(s.locals, s.declared...)
+	sort.SliceStable(this.NdLocals, func(i, j int) bool {
+		return before(this.NdLocals[i], this.NdLocals[j])
+	})
+	p.closures = append(p.closures, closure{fn: this, loop: p.outerLoop(), refs: free})
+}
+
+// outerLoop returns the outermost loop being parsed, or nil.
+func (p *Parser) outerLoop() *Token {
+	if len(p.loops) == 0 {
+		return nil
+	}
+	return p.loops[0]
+}
+
+// captures annotates the function literals of a program, once its names
+// have all been resolved.
+func (p *Parser) captures() {
+	first := map[*Token]closure{} // the earliest closure to capture each declaration
+	for _, c := range p.closures {
+		seen := map[*Token]bool{}
+		for _, r := range c.refs {
+			d := r.name.NdDeclaration
+			if d == nil || seen[d] {
+				continue
+			}
+			seen[d] = true
+			c.fn.NdCaptures = append(c.fn.NdCaptures, d)
+			d.NdCaptured = true
+			if f, ok := first[d]; !ok || before(c.fn, f.fn) {
+				first[d] = c
+			}
+		}
+		sort.SliceStable(c.fn.NdCaptures, func(i, j int) bool {
+			return before(c.fn.NdCaptures[i], c.fn.NdCaptures[j])
+		})
+	}
+	for _, a := range p.assignments {
+		d := a.name.NdDeclaration
+		c, ok := first[d]
+		if ok && (a.closure || before(c.fn, a.name) || a.loop != nil && a.loop == c.loop) {
+			d.NdReassigned = true
+		}
+	}
+}
+
+// before reports whether a comes before b in the source.
+func before(a, b *Token) bool {
+	if a.TkLine != b.TkLine {
+		return a.TkLine < b.TkLine
+	}
+	return a.TkColumn < b.TkColumn
+}
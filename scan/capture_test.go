@@ -0,0 +1,77 @@
+package scan
+
+import (
+	"reflect"
+	"testing"
+)
+
+// names returns the names of tokens, for comparing them.
+func names(tokens []*Token) []string {
+	a := []string{}
+	for _, t := range tokens {
+		a = append(a, t.TkValue)
+	}
+	return a
+}
+
+func TestCaptureAnalysis(t *testing.T) {
+	defer recoverFromPanic(t)
+	source := `let a = 1, b = 2, c = 3, d = 4;
+let f = function (x) {
+    let y = x + a;
+    let g = () => y + b;
+    { let z = 1; y = z; }
+    return g;
+};
+b = 5;
+c = 6;
+let h = function () { return c; };
+while (d) {
+    let k = () => d;
+    d = 0;
+}`
+	tree := NewParser(CaptureAnalysis()).ParseString(source)
+	s := tree.NdList
+	decls := map[string]*Token{}
+	for _, d := range s[0].NdList {
+		decls[d.NdFirst.TkValue] = d.NdFirst
+	}
+
+	f := s[1].NdSecond
+	if got := names(f.NdCaptures); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("expected f to capture a and b; got %v", got)
+	}
+	if got := names(f.NdLocals); !reflect.DeepEqual(got, []string{"x", "y", "g", "z"}) {
+		t.Errorf("expected the locals of f to be x, y, g and z; got %v", got)
+	}
+	g := f.NdSecond.NdList[1].NdSecond
+	if got := names(g.NdCaptures); !reflect.DeepEqual(got, []string{"b", "y"}) {
+		t.Errorf("expected g to capture b and y; got %v", got)
+	}
+	for _, l := range f.NdLocals {
+		if captured := l.TkValue == "y"; l.NdCaptured != captured {
+			t.Errorf("expected %s to be captured: %v", l.TkValue, captured)
+		}
+	}
+	if y := f.NdLocals[1]; !y.NdReassigned {
+		t.Errorf("expected y to be reassigned after g captures it")
+	}
+
+	// a is never assigned; b is assigned after f captures it; c is assigned
+	// only before h captures it; d is assigned before k is made again.
+	for name, reassigned := range map[string]bool{"a": false, "b": true, "c": false, "d": true} {
+		if d := decls[name]; !d.NdCaptured || d.NdReassigned != reassigned {
+			t.Errorf("expected %s to be captured and reassigned: %v; got %v, %v",
+				name, reassigned, d.NdCaptured, d.NdReassigned)
+		}
+	}
+	if h := s[4].NdSecond; !reflect.DeepEqual(names(h.NdCaptures), []string{"c"}) {
+		t.Errorf("expected h to capture c; got %v", names(h.NdCaptures))
+	}
+
+	// Without the option, nothing is annotated.
+	tree = parseString(source)
+	if f := tree.NdList[1].NdSecond; f.NdCaptures != nil || f.NdLocals != nil {
+		t.Errorf("expected no capture analysis; got %v and %v", f.NdCaptures, f.NdLocals)
+	}
+}
@@ -0,0 +1,109 @@
+package scan
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// These tests are most useful when run with the race detector:
+//
+//	go test -race ./scan
+
+var concurrentSources = []string{
+	`let a = 1, b = a + 2 * 3; b = a ?? b;`,
+	`let f = function (x, ...rest) { return x; }; f(1, 2);`,
+	`let o = {a: 1, get b() { return 2; }, [pi]: 3}; let {a, b: c = 4} = o;`,
+	"let t = `sum ${1 + 2} and ${`nested ${3}`}`;",
+	`class A { constructor() {} static *g() { yield 1; } async m() { await this; } }`,
+	`let r = /ab+c/gi, s = 'it\'s'; r = s?.length;`,
+	`import x, {y as z} from "./m.js"; export let w = x + z;`,
+	`let g = async (a) => await a, h = x => x * x;`,
+}
+
+func TestConcurrentParse(t *testing.T) {
+	parser := NewParser()
+	expected := make([]string, len(concurrentSources))
+	for i, source := range concurrentSources {
+		expected[i] = parser.ParseString(source).String()
+	}
+
+	var wg sync.WaitGroup
+	errors := make(chan string, 1000)
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for n := 0; n < 20; n++ {
+				i := (g + n) % len(concurrentSources)
+				if got := parser.ParseString(concurrentSources[i]).String(); got != expected[i] {
+					errors <- fmt.Sprintf("source %d: expected\n%s\ngot\n%s", i, expected[i], got)
+				}
+				tokens := TokenizeString(concurrentSources[0])
+				if got := parser.Parse(tokens).String(); got != expected[0] {
+					errors <- fmt.Sprintf("tokens: expected\n%s\ngot\n%s", expected[0], got)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errors)
+	for e := range errors {
+		t.Error(e)
+	}
+}
+
+func TestConcurrentSyntaxErrors(t *testing.T) {
+	parser := NewParser()
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for n := 0; n < 20; n++ {
+				func() {
+					defer func() {
+						if r, ok := recover().(*SyntaxError); !ok || !strings.Contains(r.Message, "Undefined") {
+							t.Errorf("expected `Undefined`; got %v", r)
+						}
+					}()
+					parser.ParseString(fmt.Sprintf("let a%d = b%d;", n, g))
+				}()
+				// A failed parse leaves nothing behind for the next.
+				parser.ParseString(fmt.Sprintf("let b%d = 1;", g))
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestSealedGrammar(t *testing.T) {
+	parser := NewParser()
+	parser.ParseString(`let a = 1;`)
+	defer func() {
+		if r, ok := recover().(string); !ok || !strings.Contains(r, "can't be changed") {
+			t.Errorf("expected a panic for changing a grammar in use; got %v", r)
+		}
+	}()
+	parser.Infix("mod", 60, nil)
+}
+
+func TestModuleOfConcurrentParses(t *testing.T) {
+	parser := NewParser()
+	if parser.Module() != nil {
+		t.Errorf("expected no module before the first parse")
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			parser.ParseString(fmt.Sprintf(`export let x%d = 1;`, g))
+			if m := parser.Module(); m == nil || len(m.Exports) != 1 {
+				t.Errorf("expected a module with one export; got %v", m)
+			}
+		}(g)
+	}
+	wg.Wait()
+}
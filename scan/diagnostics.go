@@ -0,0 +1,165 @@
+package scan
+
+import (
+	"fmt"
+	"sort"
+)
+
+// A Check is a kind of warning that a parser can report about a program that
+// is valid, but probably wrong.
+type Check string
+
+const (
+	// CheckUndeclared reports uses of names that are never declared. They
+	// are errors unless the parser accepts them, with UndeclaredNames.
+	CheckUndeclared Check = "undeclared"
+	// CheckUnused reports variables and parameters that are never read.
+	CheckUnused Check = "unused"
+	// CheckUnusedAssignment reports assignments to variables that are
+	// never read. Such a variable is not also reported by CheckUnused.
+	CheckUnusedAssignment Check = "unused-assignment"
+	// CheckShadow reports declarations of variables and parameters that
+	// hide a declaration of the same name in an enclosing scope.
+	CheckShadow Check = "shadow"
+)
+
+// A Warning is a problem found by a Check, at a position in the source.
+type Warning struct {
+	Check   Check
+	Line    int
+	Column  int
+	Message string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%d:%d: %s (%s)", w.Line, w.Column, w.Message, w.Check)
+}
+
+// Checks makes the parser report the warnings of the given checks in the
+// Module of each parse.
+func Checks(checks ...Check) Option {
+	return func(p *Parser) {
+		if p.checks == nil {
+			p.checks = map[Check]bool{}
+		}
+		for _, c := range checks {
+			p.checks[c] = true
+		}
+	}
+}
+
+// usage records how a declared name is used.
+type usage struct {
+	reads  int
+	writes []*Token // the targets of assignments to it
+}
+
+// warn reports a warning of check c at t, if c is enabled.
+func (p *Parser) warn(c Check, t *Token, message string) {
+	if p.checks[c] {
+		p.warnings = append(p.warnings, Warning{Check: c, Line: t.TkLine, Column: t.TkColumn, Message: message})
+	}
+}
+
+// target notes that x is the target of an assignment that doesn't read it.
+func (p *Parser) target(x *Token) {
+	if p.checks != nil && x.NdArity == nameArity {
+		p.targets[x] = true
+	}
+}
+
+// usage returns the usage of the declaration d.
+func (p *Parser) usage(d *Token) *usage {
+	u := p.uses[d]
+	if u == nil {
+		u = &usage{}
+		p.uses[d] = u
+	}
+	return u
+}
+
+// exported counts the export of d, a top-level declaration, as a read of it.
+func (p *Parser) exported(d *Token) {
+	if p.checks != nil {
+		p.usage(d).reads += 1
+	}
+}
+
+// lint checks the declarations of s, a scope that is being popped, once the
+// references that it resolved have been counted. Every use of a declaration
+// has been resolved by then, except that a `var` is checked in its function
+// scope rather than in the blocks it appears in.
+//
+// A variable that is assigned but never read is reported by
+// CheckUnusedAssignment at each assignment if that check is enabled, and
+// otherwise by CheckUnused at its declaration.
+func (p *Parser) lint(s *Scope, resolved []*reference) {
+	for _, r := range resolved {
+		u := p.usage(r.name.NdDeclaration)
+		if p.targets[r.name] {
+			u.writes = append(u.writes, r.name)
+		} else {
+			u.reads += 1
+		}
+	}
+	for _, d := range s.declared {
+		switch d.NdBinding {
+		case "let", "const", "param":
+		case "var":
+			if !s.function {
+				continue
+			}
+		default:
+			continue
+		}
+		if u := p.usage(d); u.reads == 0 {
+			if len(u.writes) == 0 || !p.checks[CheckUnusedAssignment] {
+				p.warn(CheckUnused, d, fmt.Sprintf("'%s' is never read.", d.TkValue))
+			}
+			for _, w := range u.writes {
+				p.warn(CheckUnusedAssignment, w,
+					fmt.Sprintf("'%s' is assigned but never read.", w.TkValue))
+			}
+		}
+		e := s
+		if d.NdBinding == "var" {
+			e = s.functionScope()
+		}
+		if e.parent == nil {
+			continue
+		}
+		if outer := e.parent.find(d.TkValue); outer != nil && outer.NdBinding != "" {
+			p.shadows(d, outer)
+		} else {
+			e.parent.inner = append(e.parent.inner, d)
+		}
+	}
+
+	// A declaration of a nested scope may be shadowing one of s that follows
+	// it, such as a `let` after a function that declares the same name.
+	for _, d := range s.inner {
+		if outer, ok := s.def[d.TkValue]; ok && outer != d && outer.NdBinding != "" {
+			p.shadows(d, outer)
+		} else if s.parent != nil {
+			s.parent.inner = append(s.parent.inner, d)
+		}
+	}
+}
+
+// shadows reports that the declaration d hides outer.
+func (p *Parser) shadows(d, outer *Token) {
+	p.warn(CheckShadow, d, fmt.Sprintf("'%s' shadows the declaration at %d:%d.",
+		d.TkValue, outer.TkLine, outer.TkColumn))
+}
+
+// sortWarnings puts the warnings of a parse in the order of their positions.
+func (p *Parser) sortWarnings() []Warning {
+	w := p.warnings
+	sort.SliceStable(w, func(i, j int) bool {
+		if w[i].Line != w[j].Line {
+			return w[i].Line < w[j].Line
+		}
+		return w[i].Column < w[j].Column
+	})
+	return w
+}
@@ -0,0 +1,105 @@
+package scan
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWarnings(t *testing.T) {
+	defer recoverFromPanic(t)
+	source := `let a = 1, b = 2, c;
+let f = function (x, y) {
+    let a = x;
+    c = a;
+    if (y) { var z = 1; }
+    return console;
+};
+b = 3;
+export {f};`
+	parser := NewParser(UndeclaredNames(),
+		Checks(CheckUndeclared, CheckUnused, CheckUnusedAssignment, CheckShadow))
+	_, m := parser.ParseModule(source)
+	got := []string{}
+	for _, w := range m.Warnings {
+		got = append(got, w.String())
+	}
+	expect := []string{
+		"1:4: 'a' is never read. (unused)",
+		"3:8: 'a' shadows the declaration at 1:4. (shadow)",
+		"4:4: 'c' is assigned but never read. (unused-assignment)",
+		"5:17: 'z' is never read. (unused)",
+		"6:11: 'console' is not declared. (undeclared)",
+		"8:0: 'b' is assigned but never read. (unused-assignment)",
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("expected warnings\n%s\ngot\n%s", strings.Join(expect, "\n"), strings.Join(got, "\n"))
+	}
+
+	// Each check is enabled on its own.
+	_, m = NewParser(UndeclaredNames(), Checks(CheckShadow)).ParseModule(source)
+	if len(m.Warnings) != 1 || m.Warnings[0].Check != CheckShadow {
+		t.Errorf("expected only the shadow warning; got %v", m.Warnings)
+	}
+	_, m = NewParser(UndeclaredNames()).ParseModule(source)
+	if len(m.Warnings) != 0 {
+		t.Errorf("expected no warnings without checks; got %v", m.Warnings)
+	}
+
+	// Without the unused-assignment check, a variable that is only assigned
+	// is reported as unused.
+	_, m = NewParser(UndeclaredNames(), Checks(CheckUnused)).ParseModule(source)
+	got = []string{}
+	for _, w := range m.Warnings {
+		got = append(got, w.String())
+	}
+	expect = []string{
+		"1:4: 'a' is never read. (unused)",
+		"1:11: 'b' is never read. (unused)",
+		"1:18: 'c' is never read. (unused)",
+		"5:17: 'z' is never read. (unused)",
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("expected warnings\n%s\ngot\n%s", strings.Join(expect, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestExportedAndLaterDeclarations(t *testing.T) {
+	defer recoverFromPanic(t)
+	source := `export let x = 1;
+export const y = 2;
+let g = function () { let q; return q; };
+{ let h = () => { let r = 1; return r; }; h(); }
+let q, r;
+export {g, q, r};`
+	_, m := NewParser(Checks(CheckUnused, CheckShadow)).ParseModule(source)
+	got := []string{}
+	for _, w := range m.Warnings {
+		got = append(got, w.String())
+	}
+	expect := []string{
+		"3:26: 'q' shadows the declaration at 5:4. (shadow)",
+		"4:22: 'r' shadows the declaration at 5:7. (shadow)",
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("expected warnings\n%s\ngot\n%s", strings.Join(expect, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestUnusedParameters(t *testing.T) {
+	defer recoverFromPanic(t)
+	source := `let g = (a, b) => a + 1; let h = (u) => { u = 2; }; g = h;`
+	_, m := NewParser(Checks(CheckUnused, CheckUnusedAssignment)).ParseModule(source)
+	got := []string{}
+	for _, w := range m.Warnings {
+		got = append(got, w.String())
+	}
+	expect := []string{
+		"1:12: 'b' is never read. (unused)",
+		"1:42: 'u' is assigned but never read. (unused-assignment)",
+		"1:52: 'g' is assigned but never read. (unused-assignment)",
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("expected warnings\n%s\ngot\n%s", strings.Join(expect, "\n"), strings.Join(got, "\n"))
+	}
+}
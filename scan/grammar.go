@@ -0,0 +1,305 @@
+package scan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// The methods in this file let a package that imports scan extend the
+// grammar of a Parser, in the same way that initializeSymbolTable defines
+// it, before the Parser is first used. A denotation passed to them runs while
+// the parser is positioned just after the token it belongs to; it reads the
+// rest of its construct with the Expression, Advance, Skip and Token methods
+// of the context it is passed, and returns the node it builds.
+
+// The arities that a denotation may give the node it builds.
+const (
+	UnaryArity     = unaryArity
+	BinaryArity    = binaryArity
+	TernaryArity   = ternaryArity
+	StatementArity = statementArity
+	ListArity      = listArity
+)
+
+// Symbol defines id as a token with the left binding power bp, or raises the
+// binding power of an existing symbol to bp. A symbol with no denotations,
+// such as a closing bracket, can only be skipped.
+func (p *Parser) Symbol(id string, bp int) *Token {
+	return p.symbol(id, bp)
+}
+
+// Constant defines name as a literal with the given value.
+func (p *Parser) Constant(name string, value string) *Token {
+	return p.constant(name, value)
+}
+
+// Infix defines id as a left-associative binary operator with the binding
+// power bp. If led is nil, the operator builds a binary node of its operands.
+func (p *Parser) Infix(id string, bp int, led BinaryDenotation) *Token {
+	return p.infix(id, bp, led)
+}
+
+// Infixr defines id as a right-associative binary operator with the binding
+// power bp. If led is nil, the operator builds a binary node of its operands.
+func (p *Parser) Infixr(id string, bp int, led BinaryDenotation) *Token {
+	return p.infixr(id, bp, led)
+}
+
+// Prefix defines id as a prefix operator. If nud is nil, the operator builds
+// a unary node of its operand, which binds as tightly as `!` and `-`.
+func (p *Parser) Prefix(id string, nud UnaryDenotation) *Token {
+	return p.prefix(id, nud)
+}
+
+// Assignment defines id as a right-associative assignment operator, whose
+// left operand must be assignable.
+func (p *Parser) Assignment(id string) *Token {
+	return p.assignment(id)
+}
+
+// Statement defines id as a word or punctuator that begins a statement. The
+// std parses the rest of the statement, including any ';' that ends it.
+func (p *Parser) Statement(id string, std UnaryDenotation) *Token {
+	return p.stmt(id, std)
+}
+
+// Expression parses an expression whose operators bind more tightly than
+// rbp. Use 0 for a complete expression.
+func (p *Parser) Expression(rbp int) *Token {
+	return p.expression(rbp)
+}
+
+// Block parses a block statement in braces.
+func (p *Parser) Block() *Token {
+	return p.block()
+}
+
+// Semicolon skips the ';' that ends a statement, or accepts its absence where
+// a semicolon would be inserted automatically.
+func (p *Parser) Semicolon() {
+	p.semicolon()
+}
+
+// Advance moves on to the next token.
+func (p *Parser) Advance() {
+	p.advance()
+}
+
+// Skip moves past the current token, reporting an error if its id is not id.
+func (p *Parser) Skip(id string) {
+	p.skip(id)
+}
+
+// Token returns the current token: the one after those consumed so far.
+func (p *Parser) Token() *Token {
+	return p.token
+}
+
+// A Grammar describes the symbols of a language, for NewParserFromGrammar.
+// It is read from JSON such as
+//
+//	{
+//	  "base": "javascript",
+//	  "infix": {"mod": 60},
+//	  "infixr": {"pow": 70},
+//	  "statements": {"unless": ["(", "expression", ")", "block"]}
+//	}
+//
+// A statement's shape is the sequence of steps that parse what follows its
+// keyword: "expression", "block", "name" (a new variable, declared as by
+// `let`) and "semicolon" each parse a child of the statement node, in the
+// order NdFirst, NdSecond, NdThird, except "semicolon", which parses none;
+// any other step is a symbol to be skipped.
+//
+// Tokens adds token classes to the lexer, as the TokenClass option does; the
+// tokens of each class are parsed as literals. Allow marks configurations
+// that Validate would report as intended, as Parser.Allow does.
+type Grammar struct {
+	// Base is "javascript" to extend the built-in grammar, or empty to start
+	// from only the symbols that every grammar needs.
+	Base       string              `json:"base"`
+	Symbols    []string            `json:"symbols"`
+	Constants  map[string]string   `json:"constants"`
+	Prefix     []string            `json:"prefix"`
+	Infix      map[string]int      `json:"infix"`
+	Infixr     map[string]int      `json:"infixr"`
+	Assignment []string            `json:"assignment"`
+	Groups     map[string]string   `json:"groups"` // an opening bracket and its closer, such as "(": ")"
+	Statements map[string][]string `json:"statements"`
+	Tokens     []struct {
+		Symbol  string `json:"symbol"`
+		Pattern string `json:"pattern"`
+	} `json:"tokens"`
+	Allow []struct {
+		Problem GrammarProblem `json:"problem"`
+		Symbols []string       `json:"symbols"`
+	} `json:"allow"`
+}
+
+// NewParserFromGrammar returns a Parser for the language described by the
+// JSON Grammar read from r.
+func NewParserFromGrammar(r io.Reader, options ...Option) (*Parser, error) {
+	var g Grammar
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&g); err != nil {
+		return nil, fmt.Errorf("Bad grammar: %v", err)
+	}
+	p := &Parser{}
+	switch g.Base {
+	case "javascript":
+		p.initializeSymbolTable()
+	case "":
+		p.initializeCoreSymbols()
+	default:
+		return nil, fmt.Errorf("Bad grammar: unknown base %q.", g.Base)
+	}
+	for _, id := range g.Symbols {
+		p.symbol(id, -1)
+	}
+	for _, name := range sortedKeys(g.Constants) {
+		p.constant(name, g.Constants[name])
+	}
+	for _, id := range g.Prefix {
+		p.prefix(id, nil)
+	}
+	for _, id := range sortedKeys(g.Infix) {
+		p.infix(id, g.Infix[id], nil)
+	}
+	for _, id := range sortedKeys(g.Infixr) {
+		p.infixr(id, g.Infixr[id], nil)
+	}
+	for _, id := range g.Assignment {
+		p.assignment(id)
+	}
+	for _, opener := range sortedKeys(g.Groups) {
+		closer := g.Groups[opener]
+		p.symbol(closer, -1)
+		p.prefix(opener, p.group(closer))
+		p.describe(opener).nud = form{syntax: fmt.Sprintf("%q expression %q", opener, closer)}
+	}
+	for _, id := range sortedKeys(g.Statements) {
+		shape := g.Statements[id]
+		std, err := p.shapedStatement(shape)
+		if err != nil {
+			return nil, fmt.Errorf("Bad grammar: statement %q: %v", id, err)
+		}
+		p.stmt(id, std)
+		p.describe(id).std.syntax = shapeSyntax(id, shape)
+	}
+	for _, c := range g.Tokens {
+		pattern, err := regexp.Compile(`^(?:` + c.Pattern + `)`)
+		if err != nil {
+			return nil, fmt.Errorf("Bad grammar: token %q: %v", c.Symbol, err)
+		}
+		p.classes = append(p.classes, tokenClass{symbol: c.Symbol, pattern: pattern})
+		p.symbol(c.Symbol, -1).TkNud = func(p *Parser, this *Token) *Token {
+			this.NdArity = literalArity
+			return this
+		}
+		p.describe(c.Symbol).nud = form{arity: "literal", syntax: c.Symbol}
+	}
+	for _, a := range g.Allow {
+		switch a.Problem {
+		case ProblemPowers, ProblemLostDenotation, ProblemNoLed, ProblemNudAndStd,
+			ProblemAssociativity, ProblemArity:
+			p.allow(a.Problem, a.Symbols...)
+		default:
+			return nil, fmt.Errorf("Bad grammar: unknown problem %q.", a.Problem)
+		}
+	}
+	for _, option := range options {
+		option(p)
+	}
+	return p, nil
+}
+
+// sortedKeys returns the keys of m, a map with string keys, in order, so that
+// a Grammar always registers its symbols in the same order.
+func sortedKeys(m interface{}) []string {
+	keys := []string{}
+	for _, k := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// group returns the nud of a bracket that encloses an expression.
+func (p *Parser) group(closer string) UnaryDenotation {
+	return func(p *Parser, this *Token) *Token {
+		e := p.expression(0)
+		p.skip(closer)
+		e.NdParenthesized = true
+		return e
+	}
+}
+
+// shapedStatement returns the std of a statement with the given shape,
+// defining the symbols that it skips.
+func (p *Parser) shapedStatement(shape []string) (UnaryDenotation, error) {
+	children := 0
+	for _, step := range shape {
+		switch step {
+		case "expression", "block", "name":
+			children += 1
+		case "semicolon":
+		case "":
+			return nil, fmt.Errorf("empty step")
+		default:
+			p.symbol(step, -1)
+		}
+	}
+	if children > 3 {
+		return nil, fmt.Errorf("more than three children")
+	}
+	return func(p *Parser, this *Token) *Token {
+		a := []*Token{}
+		for _, step := range shape {
+			switch step {
+			case "expression":
+				a = append(a, p.expression(0))
+			case "block":
+				a = append(a, p.block())
+			case "name":
+				n := p.token
+				if n.NdArity != nameArity {
+					n.Error("Expected a new variable name.")
+				}
+				p.scope.define(n, "let")
+				p.advance()
+				a = append(a, n)
+			case "semicolon":
+				p.semicolon()
+			default:
+				p.skip(step)
+			}
+		}
+		for i, child := range []**Token{&this.NdFirst, &this.NdSecond, &this.NdThird}[:len(a)] {
+			*child = a[i]
+		}
+		this.NdArity = statementArity
+		return this
+	}, nil
+}
+
+// shapeSyntax returns the syntax of the statement id with the given shape.
+func shapeSyntax(id string, shape []string) string {
+	syntax := []string{fmt.Sprintf("%q", id)}
+	for _, step := range shape {
+		switch step {
+		case "expression", "block", "name":
+			syntax = append(syntax, step)
+		case "semicolon":
+			syntax = append(syntax, `";"`)
+		default:
+			syntax = append(syntax, fmt.Sprintf("%q", step))
+		}
+	}
+	return strings.Join(syntax, " ")
+}
@@ -0,0 +1,207 @@
+package scan
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGrammarExtension(t *testing.T) {
+	defer recoverFromPanic(t)
+	parser := NewParser()
+	parser.Infix("mod", 60, nil)
+	parser.Infixr("pow", 70, nil)
+	parser.Prefix("not", nil)
+	parser.Assignment("*=")
+	parser.Constant("e", "2.718281828459045")
+	parser.Infix("between", 40, func(p *Parser, this, left *Token) *Token {
+		this.NdFirst = left
+		this.NdSecond = p.Expression(40)
+		if p.Token().TkValue != "and" {
+			p.Token().Error("Expected 'and'.")
+		}
+		p.Advance()
+		this.NdThird = p.Expression(40)
+		this.NdArity = TernaryArity
+		return this
+	})
+	parser.Statement("unless", func(p *Parser, this *Token) *Token {
+		p.Skip("(")
+		this.NdFirst = p.Expression(0)
+		p.Skip(")")
+		this.NdSecond = p.Block()
+		this.NdArity = StatementArity
+		return this
+	})
+
+	source := `let a = 7, b; b = a mod 3 pow 2 pow 2 + e;
+unless (not a between 1 and b + 1) { b = a; }`
+	tree := parser.ParseString(source)
+	x := tree.NdList[1].NdSecond
+	if x.NdId != "+" || x.NdFirst.NdId != "mod" || x.NdSecond.TkValue != "2.718281828459045" {
+		t.Fatalf("expected `(a mod (3 pow (2 pow 2))) + e`; got %v", x)
+	}
+	if pow := x.NdFirst.NdSecond; pow.NdId != "pow" || pow.NdSecond.NdId != "pow" {
+		t.Errorf("expected `pow` to be right-associative; got %v", pow)
+	}
+	u := tree.NdList[2]
+	if u.NdId != "unless" || u.NdArity != statementArity || u.NdFirst.NdId != "between" {
+		t.Fatalf("expected an unless statement; got %v", u)
+	}
+	if c := u.NdFirst; c.NdFirst.NdId != "not" || c.NdThird.NdId != "+" {
+		t.Errorf("expected `(not a) between 1 and (b + 1)`; got %v", c)
+	}
+
+	// A grammar extension belongs to the parser it was made on.
+	expectSyntaxError(t, `let a; a = a mod 2;`, "Expected ';'.")
+}
+
+func TestNewParserFromGrammar(t *testing.T) {
+	defer recoverFromPanic(t)
+	grammar := `{
+  "constants": {"yes": "#t", "no": "#f"},
+  "prefix": ["not", "-"],
+  "infix": {"+": 50, "*": 60, "===": 40},
+  "infixr": {"and": 30},
+  "assignment": ["="],
+  "groups": {"(": ")"},
+  "statements": {
+    "var": ["name", "=", "expression", "semicolon"],
+    "print": ["expression", "semicolon"],
+    "while": ["(", "expression", ")", "block"]
+  }
+}`
+	parser, err := NewParserFromGrammar(strings.NewReader(grammar))
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := `var n = 3;
+while (not (n === 0) and yes) { print (n + 1) * 2; n = n + -1; }`
+	tree := parser.ParseString(source)
+	v := tree.NdList[0]
+	if v.NdId != "var" || v.NdFirst.TkValue != "n" || v.NdSecond.TkValue != "3" || v.NdThird != nil {
+		t.Errorf("expected `var n = 3`; got %v", v)
+	}
+	w := tree.NdList[1]
+	if w.NdId != "while" || w.NdFirst.NdId != "and" || w.NdSecond.NdArity != listArity {
+		t.Fatalf("expected a while statement; got %v", w)
+	}
+	if p := w.NdSecond.NdList[0]; p.NdId != "print" || p.NdFirst.NdId != "*" ||
+		!p.NdFirst.NdFirst.NdParenthesized {
+		t.Errorf("expected `print (n + 1) * 2`; got %v", p)
+	}
+
+	// Only what the grammar defines is part of the language.
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("expected `let` to be undefined")
+			}
+		}()
+		parser.ParseString(`let a = 1;`)
+	}()
+
+	// A grammar may extend JavaScript.
+	parser, err = NewParserFromGrammar(strings.NewReader(
+		`{"base": "javascript", "infix": {"mod": 60}, "statements": {"unless": ["(", "expression", ")", "block"]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree = parser.ParseString(`let a = 5 mod 2; unless (a) { a = 1; }`)
+	if u := tree.NdList[1]; u.NdId != "unless" || u.NdSecond.NdId != "=" {
+		t.Errorf("expected an unless statement; got %v", u)
+	}
+
+	for _, c := range []struct {
+		grammar, message string
+	}{
+		{`{"base": "cobol"}`, `unknown base "cobol"`},
+		{`{"infix": {"+": "fifty"}}`, "Bad grammar: json"},
+		{`{"operators": {}}`, `unknown field "operators"`},
+		{`{"statements": {"s": ["expression", "block", "name", "expression"]}}`, "more than three children"},
+	} {
+		if _, err := NewParserFromGrammar(strings.NewReader(c.grammar)); err == nil ||
+			!strings.Contains(err.Error(), c.message) {
+			t.Errorf("%s: expected `%s`; got %v", c.grammar, c.message, err)
+		}
+	}
+
+	// The symbols of a grammar are registered in the same order every time.
+	grammar = `{"statements": {"b": ["expression", "block", "name", "expression"],
+  "a": ["block", "block", "block", "block"], "c": ["name", "name", "name", "name"]}}`
+	for i := 0; i < 20; i++ {
+		if _, err := NewParserFromGrammar(strings.NewReader(grammar)); err == nil ||
+			!strings.Contains(err.Error(), `statement "a"`) {
+			t.Fatalf("expected the error of statement \"a\"; got %v", err)
+		}
+	}
+}
+
+func TestPunctuatorsFromSymbols(t *testing.T) {
+	defer recoverFromPanic(t)
+	parser := NewParser()
+	parser.Infix("|>", 15, nil)
+	parser.Infixr("**", 65, nil)
+	parser.Infix("<=>", 40, nil)
+	source := `let a = 2, f, b; b = a ** 3 ** 2 * a |> f; b = a <=> b; b = a <= b;`
+	tree := parser.ParseString(source)
+	x := tree.NdList[1].NdSecond
+	if x.NdId != "|>" || x.NdFirst.NdId != "*" || x.NdFirst.NdFirst.NdId != "**" ||
+		x.NdFirst.NdFirst.NdSecond.NdId != "**" {
+		t.Errorf("expected `((a ** (3 ** 2)) * a) |> f`; got %v", x)
+	}
+	if x := tree.NdList[2].NdSecond; x.NdId != "<=>" {
+		t.Errorf("expected `a <=> b`; got %v", x)
+	}
+	if x := tree.NdList[3].NdSecond; x.NdId != "<=" {
+		t.Errorf("expected `a <= b`; got %v", x)
+	}
+
+	// Without a parser, the lexer recognizes a fixed set of punctuators.
+	tokens := TokenizeString("a |> b")
+	if len(tokens) != 4 || tokens[1].TkValue != "|" || tokens[2].TkValue != ">" {
+		t.Errorf("expected `|` and `>`; got %v", tokens)
+	}
+	// Nor does another parser: to it, `**` is two `*` operators.
+	expectSyntaxError(t, "let a = 1, b = a ** 2;", "Undefined")
+}
+
+func TestTokenClass(t *testing.T) {
+	defer recoverFromPanic(t)
+	parser := NewParser(TokenClass("(color)", `#[0-9a-fA-F]{6}\b`), TokenClass("(decorator)", `@[a-z]+`))
+	parser.Symbol("(color)", -1).TkNud = func(p *Parser, this *Token) *Token {
+		this.NdArity = literalArity
+		return this
+	}
+	parser.Prefix("(decorator)", func(p *Parser, this *Token) *Token {
+		this.NdFirst = p.Expression(70)
+		this.NdArity = UnaryArity
+		return this
+	})
+	tree := parser.ParseString(`let c = #ff8800, f, g = @memo f;`)
+	if c := tree.NdList[0].NdSecond; c.TkType != Custom || c.NdId != "(color)" || c.TkValue != "#ff8800" {
+		t.Errorf("expected a color; got %v", c)
+	}
+	if g := tree.NdList[1].NdSecond; g.NdId != "(decorator)" || g.TkValue != "@memo" || g.NdFirst.TkValue != "f" {
+		t.Errorf("expected a decorated name; got %v", g)
+	}
+	func() {
+		defer func() {
+			if r, ok := recover().(*SyntaxError); !ok || !strings.Contains(r.Message, "Unknown token class.") {
+				t.Errorf("expected `Unknown token class.`; got %v", r)
+			}
+		}()
+		NewParser(TokenClass("(unit)", `[0-9]+px`)).ParseString(`let w = 10px;`)
+	}()
+
+	parser, err := NewParserFromGrammar(strings.NewReader(
+		`{"base": "javascript", "tokens": [{"symbol": "(date)", "pattern": "\\d{4}-\\d{2}-\\d{2}"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := parser.ParseString(`let d = 2024-01-31;`).NdSecond; d.NdId != "(date)" || d.NdArity != literalArity {
+		t.Errorf("expected a date literal; got %v", d)
+	}
+	if _, err := NewParserFromGrammar(strings.NewReader(`{"tokens": [{"symbol": "(x)", "pattern": "("}]}`)); err == nil {
+		t.Errorf("expected a bad token pattern to be reported")
+	}
+}
@@ -0,0 +1,224 @@
+package scan
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// A Loader parses the modules of a program from a file system, following
+// their imports, and links them together. Its paths are relative to the root
+// of the file system; use os.DirFS to load from a directory.
+type Loader struct {
+	fsys    fs.FS
+	parser  *Parser
+	files   map[string]*File
+	order   []*File
+	loading []*File // the files whose imports are being loaded, innermost last
+}
+
+// A File is a module loaded by a Loader.
+type File struct {
+	Path    string
+	Tree    *Token
+	Module  *Module
+	Imports map[string]*File // the modules it imports, by specifier
+}
+
+// A LoadError is an error in one of the files of a program, or in the way
+// they fit together.
+type LoadError struct {
+	Path    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *LoadError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.Path, e.Line, e.Column, e.Message)
+}
+
+// NewLoader returns a Loader that reads from fsys and parses each file with
+// a Parser configured by options.
+func NewLoader(fsys fs.FS, options ...Option) *Loader {
+	return &Loader{fsys: fsys, parser: NewParser(options...), files: map[string]*File{}}
+}
+
+// Load loads the modules at paths and every module they import, directly or
+// indirectly, and checks that each imported name is exported by the module
+// it is imported from. It returns those modules, each after the modules it
+// imports. A module is parsed only once, however many times it is imported,
+// even by different calls of Load. Import cycles are errors.
+func (l *Loader) Load(paths ...string) ([]*File, error) {
+	roots := []*File{}
+	for _, p := range paths {
+		f, err := l.load(path.Clean(p), nil, nil)
+		if err != nil {
+			// Forget the files that weren't loaded completely.
+			for _, f := range l.loading {
+				delete(l.files, f.Path)
+			}
+			l.loading = nil
+			return nil, err
+		}
+		roots = append(roots, f)
+	}
+	reached := map[*File]bool{}
+	var reach func(f *File)
+	reach = func(f *File) {
+		if !reached[f] {
+			reached[f] = true
+			for _, g := range f.Imports {
+				reach(g)
+			}
+		}
+	}
+	for _, f := range roots {
+		reach(f)
+	}
+	files := []*File{}
+	for _, f := range l.order {
+		if reached[f] {
+			if err := l.link(f); err != nil {
+				return nil, err
+			}
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+// load loads the module at p, which was imported at the specifier literal at
+// in the module from, and the modules it imports.
+func (l *Loader) load(p string, from *File, at *Token) (*File, error) {
+	if f, ok := l.files[p]; ok {
+		for i, g := range l.loading {
+			if g == f {
+				return nil, l.cycle(l.loading[i:], at)
+			}
+		}
+		return f, nil
+	}
+	source, err := fs.ReadFile(l.fsys, p)
+	if err != nil {
+		if from == nil {
+			return nil, err
+		}
+		return nil, errorAt(from, at, fmt.Sprintf("Can't load module %s.", at.TkValue))
+	}
+	f := &File{Path: p, Imports: map[string]*File{}}
+	l.files[p] = f
+	l.loading = append(l.loading, f)
+	if f.Tree, f.Module, err = parseFile(l.parser, p, string(source)); err != nil {
+		return nil, err
+	}
+	for _, s := range topLevelStatements(f.Tree) {
+		if s.NdId != "import" || s.NdArity != statementArity {
+			continue
+		}
+		specifier, _ := Unquote(s.NdSecond.TkValue)
+		target, err := resolve(p, specifier)
+		if err != nil {
+			return nil, errorAt(f, s.NdSecond, err.Error())
+		}
+		if f.Imports[specifier], err = l.load(target, f, s.NdSecond); err != nil {
+			return nil, err
+		}
+	}
+	l.loading = l.loading[:len(l.loading)-1]
+	l.order = append(l.order, f)
+	return f, nil
+}
+
+// parseFile parses source, the contents of the file at path. A syntax error
+// is returned as a *LoadError rather than panicking.
+func parseFile(parser *Parser, path, source string) (tree *Token, m *Module, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*SyntaxError)
+			if !ok {
+				panic(r)
+			}
+			err = &LoadError{Path: path, Line: e.Line, Column: e.Column, Message: e.Message}
+		}
+	}()
+	tree, m = parser.ParseModule(source)
+	return tree, m, nil
+}
+
+// cycle returns the error for an import, at the specifier literal at, that
+// closes a cycle: each of files imports the next, and the last imports the
+// first.
+func (l *Loader) cycle(files []*File, at *Token) error {
+	names := []string{}
+	for _, f := range files {
+		names = append(names, f.Path)
+	}
+	names = append(names, files[0].Path)
+	return errorAt(files[len(files)-1], at, "Import cycle: "+strings.Join(names, " -> ")+".")
+}
+
+// link checks that every name f imports is exported by the module it is
+// imported from.
+func (l *Loader) link(f *File) error {
+	for _, s := range topLevelStatements(f.Tree) {
+		if s.NdId != "import" || s.NdArity != statementArity {
+			continue
+		}
+		specifier, _ := Unquote(s.NdSecond.TkValue)
+		target := f.Imports[specifier]
+		for _, m := range s.NdList {
+			name := m.NdFirst.TkValue
+			if name != "*" && !target.Module.exports(name) {
+				return errorAt(f, m.NdFirst,
+					fmt.Sprintf("'%s' is not exported by %s.", name, target.Path))
+			}
+		}
+	}
+	return nil
+}
+
+// exports reports whether m exports name.
+func (m *Module) exports(name string) bool {
+	for _, e := range m.Exports {
+		if e.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func errorAt(f *File, t *Token, message string) error {
+	return &LoadError{Path: f.Path, Line: t.TkLine, Column: t.TkColumn, Message: message}
+}
+
+// resolve returns the path of the module that specifier names when it is
+// imported by the module at from. A specifier that starts with "./" or "../"
+// is relative to the importing module; any other is relative to the root. A
+// specifier without an extension names a ".js" file.
+func resolve(from, specifier string) (string, error) {
+	var p string
+	if strings.HasPrefix(specifier, "./") || strings.HasPrefix(specifier, "../") {
+		p = path.Join(path.Dir(from), specifier)
+	} else {
+		p = path.Clean(strings.TrimPrefix(specifier, "/"))
+	}
+	if !fs.ValidPath(p) || p == "." {
+		return "", fmt.Errorf("Module specifier %q is outside the root.", specifier)
+	}
+	if path.Ext(p) == "" {
+		p += ".js"
+	}
+	return p, nil
+}
+
+// topLevelStatements returns the statements of a parsed program.
+func topLevelStatements(tree *Token) []*Token {
+	if tree == nil {
+		return nil
+	} else if tree.NdId == "statements" && tree.NdArity == listArity {
+		return tree.NdList
+	}
+	return []*Token{tree}
+}
@@ -0,0 +1,88 @@
+package scan
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoader(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.js":        {Data: []byte(`import {add} from "./lib/math.js"; import * as fmt from "fmt"; let x = add(1, 2); fmt.print(x);`)},
+		"lib/math.js":    {Data: []byte(`import {tau} from "../constants"; export let add = function (a, b) { return a + b; };`)},
+		"constants.js":   {Data: []byte(`export const tau = 6.28;`)},
+		"fmt.js":         {Data: []byte(`import {tau} from "./constants.js"; export let print = function (x) { return x; };`)},
+		"cycle/a.js":     {Data: []byte(`import {b} from "./b.js"; export let a = 1;`)},
+		"cycle/b.js":     {Data: []byte(`import {a} from "./a.js"; export let b = 1;`)},
+		"missing.js":     {Data: []byte(`import {nothing} from "./constants.js";`)},
+		"nowhere.js":     {Data: []byte(`import "./nonexistent.js";`)},
+		"outside.js":     {Data: []byte(`import "../up.js";`)},
+		"bad/syntax.js":  {Data: []byte("let a = 1;\nlet a = 2;")},
+		"bad/import.js":  {Data: []byte(`import "./syntax.js";`)},
+		"bad/end.js":     {Data: []byte("let a = 1;\nlet b = (a")},
+		"cycle/self.js":  {Data: []byte(`import "./self.js";`)},
+		"cycle/three.js": {Data: []byte(`import "./a.js";`)},
+	}
+
+	loader := NewLoader(fsys)
+	files, err := loader.Load("main.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{}
+	for _, f := range files {
+		paths = append(paths, f.Path)
+	}
+	if got := strings.Join(paths, " "); got != "constants.js lib/math.js fmt.js main.js" {
+		t.Errorf("expected the modules in dependency order; got %s", got)
+	}
+	main := files[3]
+	if main.Imports["./lib/math.js"] != files[1] || main.Imports["fmt"] != files[2] {
+		t.Errorf("expected main.js to import lib/math.js and fmt.js; got %v", main.Imports)
+	}
+	if files[1].Imports["../constants"] != files[2].Imports["./constants.js"] {
+		t.Errorf("expected constants.js to be loaded only once")
+	}
+
+	for _, c := range []struct {
+		path, message string
+	}{
+		{"cycle/a.js", "cycle/b.js:1:16: Import cycle: cycle/a.js -> cycle/b.js -> cycle/a.js."},
+		{"cycle/self.js", "Import cycle: cycle/self.js -> cycle/self.js."},
+		{"missing.js", "missing.js:1:8: 'nothing' is not exported by constants.js."},
+		{"nowhere.js", `nowhere.js:1:7: Can't load module "./nonexistent.js".`},
+		{"outside.js", `Module specifier "../up.js" is outside the root.`},
+		{"bad/import.js", "bad/syntax.js:2:4: Already defined"},
+		{"bad/end.js", "bad/end.js:2:10: Expected ')'."},
+		{"absent.js", "file does not exist"},
+	} {
+		_, err := NewLoader(fsys).Load(c.path)
+		if err == nil || !strings.Contains(err.Error(), c.message) {
+			t.Errorf("%s: expected `%s`; got %v", c.path, c.message, err)
+		}
+	}
+
+	// A loader can go on after an error.
+	loader = NewLoader(fsys)
+	if _, err := loader.Load("cycle/three.js"); err == nil {
+		t.Errorf("expected an import cycle")
+	}
+	if _, err := loader.Load("constants.js"); err != nil {
+		t.Errorf("expected constants.js to load; got %v", err)
+	}
+
+	// Each call returns only the modules it reaches, parsing each only once.
+	loader = NewLoader(fsys)
+	first, err := loader.Load("lib/math.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := loader.Load("fmt.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 2 || len(second) != 2 || first[0] != second[0] ||
+		first[1].Path != "lib/math.js" || second[1].Path != "fmt.js" {
+		t.Errorf("expected constants.js and the module loaded by each call; got %v and %v", first, second)
+	}
+}
@@ -0,0 +1,183 @@
+package scan
+
+import "fmt"
+
+// A Module summarizes the imports and exports of a parsed file, and holds
+// the warnings of the checks the parser was configured with.
+type Module struct {
+	Imports  []Import
+	Exports  []Export
+	Warnings []Warning
+}
+
+// An Import is a binding imported from another module. Name is the name that
+// module exports it as: "default" for its default export, or "*" for the
+// namespace object holding all of its exports. An import that binds nothing,
+// `import "m"`, has an empty Name and Local.
+type Import struct {
+	Source string // the module specifier, such as "./util.js"
+	Name   string
+	Local  string // the name it is bound to in this module
+}
+
+// An Export is a name exported by a module. Local is the binding it exports,
+// or "" for the value of an `export default` expression.
+type Export struct {
+	Name  string
+	Local string
+}
+
+// topLevel reports an error at t, an import or export statement, unless it
+// is at the top level of the program.
+func (p *Parser) topLevel(t *Token) {
+	if p.scope.parent != nil {
+		t.Error(fmt.Sprintf("'%s' must be at the top level.", t.TkValue))
+	}
+}
+
+// moduleSource parses the `from "specifier"` that ends an import.
+func (p *Parser) moduleSource() (*Token, string) {
+	if p.token.TkValue != "from" {
+		p.token.Error("Expected 'from'.")
+	}
+	p.advance()
+	return p.specifier()
+}
+
+// specifier parses a string literal naming a module, returning the literal
+// and the name it quotes.
+func (p *Parser) specifier() (*Token, string) {
+	s := p.token
+	if s.TkType != Literal || (s.TkValue[0] != '"' && s.TkValue[0] != '\'') {
+		s.Error("Expected a module specifier.")
+	}
+	source, err := Unquote(s.TkValue)
+	if err != nil {
+		s.Error(err.Error())
+	}
+	p.advance()
+	s.NdArity = literalArity
+	return s, source
+}
+
+// importBinding defines local, the current token, as the local name of an
+// import. It returns a property node whose key is the imported name and whose
+// value is the local name.
+func (p *Parser) importBinding(name string) *Token {
+	local := p.token
+	if local.NdArity != nameArity {
+		local.Error("Expected a name to import into.")
+	}
+	m := newProperty(local)
+	m.NdKind = "import"
+	m.NdFirst = &Token{
+		TkType:   Literal,
+		TkValue:  name,
+		TkLine:   local.TkLine,
+		TkColumn: local.TkColumn,
+		NdId:     "(literal)",
+		NdArity:  literalArity,
+	}
+	p.scope.define(local, "import")
+	m.NdSecond = local
+	p.advance()
+	return m
+}
+
+// importClause parses the bindings of an import statement, up to but not
+// including the `from`: a default binding, a namespace binding `* as ns`, a
+// list of named bindings `{a, b as c}`, or a default binding followed by one
+// of the other two.
+func (p *Parser) importClause() []*Token {
+	a := []*Token{}
+	if p.token.NdArity == nameArity {
+		a = append(a, p.importBinding("default"))
+		if p.token.NdId != "," {
+			return a
+		}
+		p.skip(",")
+	}
+	if p.token.NdId == "*" {
+		p.skip("*")
+		p.contextual("as")
+		return append(a, p.importBinding("*"))
+	}
+	p.skip("{")
+	for p.token.NdId != "}" {
+		name := p.token.TkValue
+		if p.lookahead().TkValue == "as" {
+			if p.token.TkType != Name && p.token.TkType != Literal {
+				p.token.Error("Expected a name to import.")
+			} else if p.token.TkType == Literal {
+				var err error
+				if name, err = Unquote(name); err != nil {
+					p.token.Error("Expected a name to import.")
+				}
+			}
+			p.advance()
+			p.contextual("as")
+		}
+		// Otherwise the imported name is also the local name.
+		a = append(a, p.importBinding(name))
+		if p.token.NdId != "," {
+			break
+		}
+		p.skip(",")
+	}
+	p.skip("}")
+	return a
+}
+
+// exportList parses the names of an `export {a, b as c}` statement. Each is
+// a property node whose key is the exported name and whose value is a
+// reference to the local name.
+func (p *Parser) exportList() []*Token {
+	a := []*Token{}
+	p.skip("{")
+	for p.token.NdId != "}" {
+		local := p.token
+		if local.NdArity != nameArity {
+			local.Error("Expected a name to export.")
+		}
+		m := newProperty(local)
+		m.NdKind = "export"
+		p.advance()
+		m.NdSecond = local.TkNud(p, local)
+		m.NdFirst = local.literal()
+		if p.token.TkValue == "as" {
+			p.contextual("as")
+			if p.token.TkType != Name {
+				p.token.Error("Expected an export name.")
+			}
+			m.NdFirst = p.token.literal()
+			p.advance()
+		}
+		a = append(a, m)
+		if p.token.NdId != "," {
+			break
+		}
+		p.skip(",")
+	}
+	p.skip("}")
+	return a
+}
+
+// contextual skips the current token, which must be the name v. Words such as
+// `as` and `from` are keywords only in certain places, so they are not in the
+// symbol table.
+func (p *Parser) contextual(v string) {
+	if p.token.TkType != Name || p.token.TkValue != v {
+		p.token.Error(fmt.Sprintf("Expected '%s'.", v))
+	}
+	p.advance()
+}
+
+// export records the export of local under name in the module summary.
+func (p *Parser) export(at *Token, name, local string) {
+	for _, e := range p.module.Exports {
+		if e.Name == name {
+			at.Error(fmt.Sprintf("Duplicate export of '%s'.", name))
+		}
+	}
+	p.module.Exports = append(p.module.Exports, Export{Name: name, Local: local})
+}
@@ -1,53 +1,276 @@
 package scan
 
-import "fmt"
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
 
+// A Parser parses programs in the language described by its symbol table.
+// Once it has parsed a program its grammar can't be changed, and it may be
+// used for any number of parses at once: each parse is carried out by a
+// context, a Parser of its own that shares the symbol table, and the
+// denotations of the symbols are passed the context they run in.
 type Parser struct {
 	// actually, this is more of a parse_table,
 	// because its contents are tokens that direct the parsing
 	symbol_table map[string]*Token
-	scope        *Scope
 
+	asi         bool           // automatic semicolon insertion
+	globals     bool           // accept undeclared names as globals
+	checks      map[Check]bool // the warnings to report
+	capture     bool           // analyze the variables that closures capture
+	classes     []tokenClass   // custom token classes for the lexer
+	punctuation *trie          // the symbols spelled with punctuation characters
+	trace       func(TraceEvent)
+
+	// descriptions holds what the registration methods know about the
+	// symbols, for Symbols and WriteDocs.
+	descriptions map[string]*description
+
+	// allowed holds the configurations that Validate doesn't report, by
+	// problem and symbols.
+	allowed map[string]bool
+
+	once   sync.Once
+	sealed int32        // nonzero once the grammar can no longer be changed
+	last   atomic.Value // the *Module of the most recent parse
+
+	// The state of a parse, in a context.
+	depth       int // the number of calls of expression in progress
+	scope       *Scope
 	token       *Token
 	tokenNumber int
 	tokens      []*Token
+	covers      []*Token          // shorthand property initializers not yet in patterns
+	targets     map[*Token]bool   // names assigned to without being read
+	uses        map[*Token]*usage // by declaration
+	warnings    []Warning
+	loops       []*Token          // the loops being parsed, outermost first
+	writes      map[*Token]*Token // assigned names: the outermost loop around each
+	closures    []closure
+	assignments []assignment
+
+	// When parsing source text, lexer supplies tokens as they are needed,
+	// so that a '/' where an operand is expected can be lexed again as a
+	// regular expression.
+	lexer *lexer
+
+	module *Module // the imports and exports of the program being parsed
+}
+
+// An Option configures a Parser.
+type Option func(p *Parser)
+
+// AutomaticSemicolons makes the parser follow JavaScript's rules for
+// automatic semicolon insertion, so that a statement may end without a ';'
+// before a '}', at the end of input, or at a line break.
+func AutomaticSemicolons() Option {
+	return func(p *Parser) {
+		p.asi = true
+	}
+}
+
+// UndeclaredNames makes the parser accept names that are never declared,
+// taking them to be globals, and mark them NdUndeclared. Otherwise they are
+// errors.
+func UndeclaredNames() Option {
+	return func(p *Parser) {
+		p.globals = true
+	}
+}
+
+// TokenClass adds a class of tokens to the lexer: those matching pattern, a
+// regular expression, at the current position. They are parsed as the symbol
+// named symbol, which the grammar must define. Token classes are tried in the
+// order they are added, and before the lexer's built-in rules. TokenClass
+// panics if pattern is not a valid regular expression.
+func TokenClass(symbol, pattern string) Option {
+	c := tokenClass{symbol: symbol, pattern: regexp.MustCompile(`^(?:` + pattern + `)`)}
+	return func(p *Parser) {
+		p.classes = append(p.classes, c)
+	}
 }
 
-func NewParser() (p *Parser) {
+func NewParser(options ...Option) (p *Parser) {
 	p = &Parser{}
 	p.initializeSymbolTable()
+	for _, option := range options {
+		option(p)
+	}
 	return
 }
 
 func (p *Parser) Parse(array_of_tokens []*Token) *Token {
+	c := p.context()
+	s := c.parse(array_of_tokens)
+	p.last.Store(c.module)
+	return s
+}
+
+// ParseString tokenizes and parses source. Unlike Parse, it lets the lexer
+// know whether an operand or an operator is expected at each '/', and the
+// lexer recognizes the punctuators and token classes of this parser's grammar
+// rather than a fixed set.
+func (p *Parser) ParseString(source string) *Token {
+	s, _ := p.ParseModule(source)
+	return s
+}
+
+// ParseModule is like ParseString, but also returns the summary of the
+// imports and exports of source.
+func (p *Parser) ParseModule(source string) (*Token, *Module) {
+	c := p.context()
+	c.lexer = &lexer{
+		lines:       splitLines(source),
+		punctuators: c.punctuation,
+		classes:     c.classes,
+	}
+	s := c.parse([]*Token{})
+	p.last.Store(c.module)
+	return s, c.module
+}
+
+// Module returns the summary of the imports and exports of the program most
+// recently parsed, or nil if no parse has finished. When a Parser is used for
+// several parses at once, use ParseModule instead.
+func (p *Parser) Module() *Module {
+	m, _ := p.last.Load().(*Module)
+	return m
+}
+
+// context seals the grammar of p and returns a new context in which to
+// parse with it.
+func (p *Parser) context() *Parser {
+	p.once.Do(func() {
+		p.punctuation = p.punctuators()
+		atomic.StoreInt32(&p.sealed, 1)
+	})
+	return &Parser{
+		symbol_table: p.symbol_table,
+		asi:          p.asi,
+		globals:      p.globals,
+		checks:       p.checks,
+		capture:      p.capture,
+		classes:      p.classes,
+		punctuation:  p.punctuation,
+		trace:        p.trace,
+		descriptions: p.descriptions,
+		sealed:       1,
+	}
+}
+
+// punctuators returns a trie of the symbols that are spelled with punctuation
+// characters.
+func (p *Parser) punctuators() *trie {
+	t := &trie{}
+	for id := range p.symbol_table {
+		if isPunctuator(id) {
+			t.insert(id)
+		}
+	}
+	return t
+}
+
+func (p *Parser) parse(array_of_tokens []*Token) *Token {
+	p.module = &Module{}
 	p.tokens = array_of_tokens
 	p.tokenNumber = 0
-	p.newScope()
+	if p.checks != nil {
+		p.targets = map[*Token]bool{}
+		p.uses = map[*Token]*usage{}
+	}
+	if p.capture {
+		p.writes = map[*Token]*Token{}
+	}
+	p.newFunctionScope()
 
 	p.advance()
 	s := p.statements()
 	p.skip("(end)")
 	p.popScope()
+	p.module.Warnings = p.sortWarnings()
+	if p.capture {
+		p.captures()
+	}
 	return s
 }
 
-func (p *Parser) popScope() {
-	p.scope = p.scope.parent
+// popScope closes the current scope. References it could not resolve are
+// passed to the parent scope, and returned; at the outermost scope they are
+// undeclared.
+func (p *Parser) popScope() []*reference {
+	s := p.scope
+	resolved, unresolved := s.resolve()
+	if p.checks != nil {
+		p.lint(s, resolved)
+	}
+	if p.capture {
+		for _, r := range resolved {
+			if loop, ok := p.writes[r.name]; ok {
+				p.assignments = append(p.assignments, assignment{name: r.name, closure: r.closure, loop: loop})
+			}
+		}
+		if !s.function {
+			f := s.parent.functionScope()
+			for _, d := range s.declared {
+				if d.NdBinding != "var" {
+					f.locals = append(f.locals, d)
+				}
+			}
+		}
+	}
+	p.scope = s.parent
+	if len(unresolved) == 0 {
+		return nil
+	}
+	if p.scope == nil {
+		if !p.globals {
+			unresolved[0].name.Error("Undefined")
+		}
+		for _, r := range unresolved {
+			r.name.NdUndeclared = true
+			p.warn(CheckUndeclared, r.name, fmt.Sprintf("'%s' is not declared.", r.name.TkValue))
+		}
+		return unresolved
+	}
+	p.scope.pending = append(p.scope.pending, unresolved...)
+	return unresolved
 }
 
 func (p *Parser) newScope() {
 	p.scope = &Scope{def: map[string]*Token{}, parent: p.scope}
 }
 
+// newFunctionScope opens the scope of a function body, which is where `var`
+// declarations are defined.
+func (p *Parser) newFunctionScope() {
+	p.newScope()
+	p.scope.function = true
+}
+
+// keyword reports whether name, which is in the symbol table, is a keyword
+// where it appears: `yield` is one only in a generator, and `await` only in
+// an async function. Elsewhere they are ordinary names.
+func (p *Parser) keyword(name string) bool {
+	switch name {
+	case "yield":
+		return p.scope.functionScope().generator
+	case "await":
+		return p.scope.functionScope().async
+	}
+	return true
+}
+
 func (p *Parser) findInScope(name string) *Token {
 	if t := p.scope.find(name); t != nil {
 		return t
-	} else if tok, ok := p.symbol_table[name]; ok {
+	} else if tok, ok := p.symbol_table[name]; ok && p.keyword(name) {
 		return tok
 	} else {
-		t := p.symbol_table["(name)"]
-		t.NdArity = nameArity
-		return t
+		return p.symbol_table["(name)"]
 	}
 }
 
@@ -56,6 +279,9 @@ func (p *Parser) reserveInScope(t *Token) {
 }
 
 func (p *Parser) symbol(name string, bp int) *Token {
+	if atomic.LoadInt32(&p.sealed) != 0 {
+		panic("scan: the grammar of a Parser can't be changed once it has been used")
+	}
 	var s *Token
 	var ok bool
 	if s, ok = p.symbol_table[name]; ok {
@@ -67,40 +293,40 @@ func (p *Parser) symbol(name string, bp int) *Token {
 			NdId:    name,
 			TkValue: name,
 			TkLbp:   bp,
-			TkNud:   func(this *Token) *Token { this.Error("Undefined"); return this },
-			TkLed: func(this, left *Token) *Token {
-				this.Error(fmt.Sprintf("Missing operator; left: %v", left))
-				return this
-			},
 		}
 		p.symbol_table[name] = s
 	}
+	if bp >= 0 {
+		p.describe(name).powers[bp] = true
+	}
 	return s
 }
 
 func (p *Parser) constant(s string, v string) *Token {
 	x := p.symbol(s, -1)
-	x.TkNud = func(this *Token) *Token {
-		//DEBUG fmt.Printf("constant %s: %v\n", v, this)
+	x.TkNud = func(p *Parser, this *Token) *Token {
 		p.reserveInScope(this)
 		this.TkValue = p.symbol_table[this.NdId].TkValue
 		this.NdArity = literalArity
 		return this
 	}
 	x.TkValue = v
+	p.describe(s).nud = form{arity: "literal", syntax: fmt.Sprintf("%q", s)}
 	return x
 }
 
 func (p *Parser) infix(id string, bp int, led BinaryDenotation) *Token {
 	s := p.symbol(id, bp)
 	s.TkLed = led
+	d := p.describe(id)
+	d.associativity = "left"
+	d.led = form{arity: "binary", syntax: fmt.Sprintf("expression %q …", id)}
 	if led == nil {
-		s.TkLed = func(this, left *Token) *Token {
+		d.led.syntax = fmt.Sprintf("expression %q expression(%d)", id, bp)
+		s.TkLed = func(p *Parser, this, left *Token) *Token {
 			this.NdFirst = left
-			//DEBUG fmt.Printf("infix after NdFirst: %v\n", this)
 			this.NdSecond = p.expression(bp)
 			this.NdArity = binaryArity
-			//DEBUG fmt.Printf("infix after NdSecond: %v\n", this)
 			return this
 		}
 	}
@@ -110,48 +336,89 @@ func (p *Parser) infix(id string, bp int, led BinaryDenotation) *Token {
 func (p *Parser) infixr(id string, bp int, led BinaryDenotation) *Token {
 	s := p.symbol(id, bp)
 	s.TkLed = led
+	d := p.describe(id)
+	d.associativity = "right"
+	d.led = form{arity: "binary", syntax: fmt.Sprintf("expression %q …", id)}
 	if led == nil {
-		s.TkLed = func(this, left *Token) *Token {
+		d.led.syntax = fmt.Sprintf("expression %q expression(%d)", id, bp-1)
+		s.TkLed = func(p *Parser, this, left *Token) *Token {
 			this.NdFirst = left
-			//DEBUG fmt.Printf("infixr after NdFirst: %v\n", this)
 			this.NdSecond = p.expression(bp - 1)
 			this.NdArity = binaryArity
-			//DEBUG fmt.Printf("infixr after NdSecond: %v\n", this)
 			return this
 		}
 	}
 	return s
 }
 
+// possibleLvalue reports whether x can be assigned to: a name, or a member
+// access with no optional link anywhere in its chain, as in `a?.b.c`.
 func possibleLvalue(x *Token) bool {
-	return x.NdId == "." || x.NdId == "[" || x.NdArity == nameArity
+	if x.NdArity == nameArity {
+		return true
+	}
+	if x.NdId != "." && x.NdId != "[" {
+		return false
+	}
+	for e := x; e != nil && (e.NdId == "." || e.NdId == "[" || e.NdId == "("); e = e.NdFirst {
+		if e.NdOptional {
+			return false
+		}
+	}
+	return true
+}
+
+// logical returns the led of a logical operator: `&&`, `||` or `??`. An
+// operand of `??` can't be an unparenthesized `&&` or `||` expression, nor
+// the other way around.
+func (p *Parser) logical(bp int) BinaryDenotation {
+	mixed := func(this, operand *Token) {
+		if operand.NdArity != binaryArity || operand.NdParenthesized {
+			return
+		}
+		if (this.NdId == "??") != (operand.NdId == "??") &&
+			(operand.NdId == "??" || operand.NdId == "&&" || operand.NdId == "||") {
+			operand.Error("Can't mix '??' with '&&' or '||' without parentheses.")
+		}
+	}
+	return func(p *Parser, this, left *Token) *Token {
+		mixed(this, left)
+		this.NdFirst = left
+		this.NdSecond = p.expression(bp)
+		mixed(this, this.NdSecond)
+		this.NdArity = binaryArity
+		return this
+	}
 }
 
 func (p *Parser) assignment(id string) *Token {
-	return p.infixr(id, 10, func(this, left *Token) *Token {
-		//DEBUG fmt.Printf("assignment after NdFirst: %v\n", this)
-		if !possibleLvalue(left) {
-			left.Error("Bad lvalue.")
+	s := p.infixr(id, 10, func(p *Parser, this, left *Token) *Token {
+		if id == "=" {
+			left = p.toPattern(left)
+		} else {
+			p.assignable(left)
 		}
 		this.NdFirst = left
 		this.NdSecond = p.expression(9)
 		this.NdAssignment = true
 		this.NdArity = binaryArity
-		//DEBUG fmt.Printf("assignment after NdSecond: %v\n", this)
 		return this
 	})
+	p.describe(id).led.syntax = fmt.Sprintf("target %q expression(9)", id)
+	return s
 }
 
 func (p *Parser) prefix(id string, nud UnaryDenotation) *Token {
 	s := p.symbol(id, -1)
 	s.TkNud = nud
+	d := p.describe(id)
+	d.nud = form{syntax: fmt.Sprintf("%q …", id)}
 	if nud == nil {
-		s.TkNud = func(this *Token) *Token {
-			//DEBUG fmt.Printf("prefix before NdFirst: %v\n", this)
+		d.nud = form{arity: "unary", syntax: fmt.Sprintf("%q expression(70)", id)}
+		s.TkNud = func(p *Parser, this *Token) *Token {
 			p.reserveInScope(this)
 			this.NdFirst = p.expression(70)
 			this.NdArity = unaryArity
-			//DEBUG fmt.Printf("prefix after NdFirst: %v\n", this)
 			return this
 		}
 	}
@@ -161,6 +428,7 @@ func (p *Parser) prefix(id string, nud UnaryDenotation) *Token {
 func (p *Parser) stmt(id string, std UnaryDenotation) *Token {
 	x := p.symbol(id, -1)
 	x.TkStd = std
+	p.describe(id).std = form{arity: "statement", syntax: fmt.Sprintf("%q …", id)}
 	return x
 }
 
@@ -171,9 +439,53 @@ func (p *Parser) skip(id string) {
 	p.advance()
 }
 
+// fetch reports whether there is an i'th token, lexing up to it if need be.
+func (p *Parser) fetch(i int) bool {
+	for p.lexer != nil && i >= len(p.tokens) {
+		t := p.lexer.next(false)
+		if t == nil {
+			return false
+		}
+		p.tokens = append(p.tokens, t)
+	}
+	return i < len(p.tokens)
+}
+
+// end returns the position just after the last token, where the end of the
+// input is reported.
+func (p *Parser) end() (line, column int) {
+	if len(p.tokens) == 0 {
+		return 1, 0
+	}
+	t := p.tokens[len(p.tokens)-1]
+	line, column = t.TkLine, t.TkColumn+len(t.TkValue)
+	if i := strings.LastIndex(t.TkValue, "\n"); i >= 0 {
+		line += strings.Count(t.TkValue, "\n")
+		column = len(t.TkValue) - i - 1
+	}
+	return line, column
+}
+
+// operand lexes the current token again as the start of an operand, when it
+// was lexed as a '/' or '/=' operator but an operand is expected.
+func (p *Parser) operand() {
+	if p.lexer == nil || p.token.TkType != Punctuator ||
+		(p.token.TkValue != "/" && p.token.TkValue != "/=") {
+		return
+	}
+	n := p.tokenNumber - 1
+	p.lexer.rewind(n)
+	p.tokens = append(p.tokens[:n], p.lexer.next(true))
+	p.tokenNumber = n
+	p.advance()
+}
+
 func (p *Parser) advance() {
-	if p.tokenNumber >= len(p.tokens) {
-		p.token = p.symbol_table["(end)"]
+	if !p.fetch(p.tokenNumber) {
+		end := *p.symbol_table["(end)"]
+		end.TkLine, end.TkColumn = p.end()
+		p.token = &end
+		p.traceStep(TraceAdvance, p.token, 0)
 		return
 	}
 	t := p.tokens[p.tokenNumber]
@@ -191,51 +503,130 @@ func (p *Parser) advance() {
 	} else if a == String || a == Fixnum || a == Flonum {
 		o = p.symbol_table["(literal)"]
 		a = Literal
+	} else if (a == Template || a == TemplateHead) && v[0] == '}' {
+		o = p.symbol_table["(template part)"]
+	} else if a == Template || a == TemplateHead {
+		o = p.symbol_table["(template)"]
+	} else if a == Regexp {
+		o = p.symbol_table["(regexp)"]
+	} else if a == Custom {
+		if o, ok = p.symbol_table[t.NdId]; !ok {
+			t.Error("Unknown token class.")
+		}
+	} else if a == UnterminatedString && v[0] == '`' {
+		t.Error("Unterminated template.")
+	} else if a == UnterminatedString {
+		t.Error("Unterminated string.")
+	} else if a == Error {
+		t.Error(v)
 	} else {
 		t.Error("Unexpected token.")
 	}
-	p.token = &Token{}
-	*p.token = *o
-	p.token.TkLine = t.TkLine
-	p.token.TkColumn = t.TkColumn
-	p.token.TkValue = v
-	p.token.TkType = a
-	//fmt.Printf("next token: %v\n", p.token)
+	// Copy only the behaviour of o: when o is a name defined in scope it is
+	// also a node in the tree, and its children must not leak into the copy.
+	p.token = &Token{
+		TkType:     a,
+		TkValue:    v,
+		TkLine:     t.TkLine,
+		TkColumn:   t.TkColumn,
+		TkNewline:  t.TkNewline,
+		TkReserved: o.TkReserved,
+		TkNud:      o.TkNud,
+		TkLed:      o.TkLed,
+		TkStd:      o.TkStd,
+		TkLbp:      o.TkLbp,
+		NdId:       o.NdId,
+		NdArity:    o.NdArity,
+	}
+	p.traceStep(TraceAdvance, p.token, 0)
 }
 
 func (p *Parser) expression(rbp int) *Token {
+	p.operand()
 	t := p.token
+	p.depth += 1
+	p.traceStep(TraceExpression, t, rbp)
 	p.advance()
 	if t == nil {
 		panic("expression: initial token is nil")
 	}
-	if t.TkNud == nil {
-		panic(fmt.Sprintf("expression: nil nud for %s", t))
-		return t
+	var left *Token
+	if t.NdArity == nameArity && p.token.NdId == "=>" {
+		// A lone parameter name, as in `x => x + 1`.
+		p.newFunctionScope()
+		p.scope.define(t, "param")
+		left = p.arrowBody([]*Token{t})
+	} else if p.asyncAhead(t) {
+		left = p.async(t)
+	} else if t.TkNud == nil {
+		t.Error("Undefined")
+	} else {
+		p.traceStep(TraceNud, t, rbp)
+		left = t.TkNud(p, t)
 	}
-	left := t.TkNud(t)
-	for rbp < p.token.TkLbp {
+	for p.binds(rbp) {
 		t = p.token
+		if t.TkLed == nil {
+			t.Error(fmt.Sprintf("Missing operator; left: %v", left))
+		}
+		p.traceStep(TraceLed, t, rbp)
 		p.advance()
-		left = t.TkLed(t, left)
+		left = t.TkLed(p, t, left)
 	}
+	p.traceStep(TraceReturn, left, rbp)
+	p.depth -= 1
 	return left
 }
 
+// binds reports whether the current token binds more tightly than rbp, and
+// so takes the expression parsed so far as its left operand.
+func (p *Parser) binds(rbp int) bool {
+	p.traceStep(TraceCompare, p.token, rbp)
+	return rbp < p.token.TkLbp
+}
+
 func (p *Parser) statement() *Token {
 	n := p.token
+	covers := len(p.covers)
 
+	var s *Token
 	if n.TkStd != nil {
+		p.traceStep(TraceStd, n, 0)
 		p.advance()
 		p.reserveInScope(n)
-		return n.TkStd(n)
+		s = n.TkStd(p, n)
+	} else {
+		s = p.expression(0)
+		if !s.NdAssignment && s.NdId != "(" &&
+			s.NdId != "yield" && s.NdId != "yield*" && s.NdId != "await" {
+			s.Error(fmt.Sprintf("Bad expression statement (toplevel is %v).", s))
+		}
+		p.semicolon()
 	}
-	v := p.expression(0)
-	if !v.NdAssignment && v.NdId != "(" {
-		v.Error(fmt.Sprintf("Bad expression statement (toplevel is %v).", v))
+	// The object literals of the statement can no longer become patterns.
+	for _, c := range p.covers[covers:] {
+		if c != nil {
+			c.Error("Invalid shorthand property initializer.")
+		}
+	}
+	p.covers = p.covers[:covers]
+	return s
+}
+
+// statementEnds reports whether the current statement ends before the current
+// token: at a ';' or, with automatic semicolon insertion, at a '}', at the end
+// of input or at a line break.
+func (p *Parser) statementEnds() bool {
+	id := p.token.NdId
+	return id == ";" || p.asi && (id == "}" || id == "(end)" || p.token.TkNewline)
+}
+
+// semicolon skips the ';' that ends a statement, or accepts its absence where
+// a semicolon would be inserted automatically.
+func (p *Parser) semicolon() {
+	if p.token.NdId == ";" || !p.statementEnds() {
+		p.skip(";")
 	}
-	p.skip(";")
-	return v
 }
 
 func (p *Parser) statements() *Token {
@@ -266,25 +657,707 @@ func (p *Parser) statements() *Token {
 func (p *Parser) block() *Token {
 	t := p.token
 	p.skip("{")
-	return t.TkStd(t)
+	return t.TkStd(p, t)
+}
+
+// parameters parses a comma-separated list of parameters up to (but not
+// including) the closing ')', defining each name they bind in the current
+// scope.
+func (p *Parser) parameters() []*Token {
+	a := []*Token{}
+	if p.token.NdId != ")" {
+		for {
+			if p.token.NdId == "..." {
+				a = append(a, p.rest("param"))
+				if p.token.NdId != ")" {
+					p.token.Error("Rest parameter must be last.")
+				}
+				break
+			}
+			a = append(a, p.defaultValue(p.pattern("param")))
+			if p.token.NdId != "," {
+				break
+			}
+			p.skip(",")
+		}
+	}
+	return a
+}
+
+// pattern parses a binding target: a name, or an array or object
+// destructuring pattern whose elements may have default values and end with a
+// rest element. Every name it binds is defined in the current scope as a
+// declaration of the given kind.
+func (p *Parser) pattern(kind string) *Token {
+	t := p.token
+	if t.NdArity == nameArity {
+		p.scope.define(t, kind)
+		p.advance()
+		return t
+	}
+	if t.NdId != "[" && t.NdId != "{" {
+		if kind == "param" {
+			t.Error("Expected a parameter name.")
+		}
+		t.Error("Expected a new variable name.")
+	}
+	p.advance()
+	a := []*Token{}
+	closer := "]"
+	if t.NdId == "{" {
+		closer = "}"
+	}
+	for p.token.NdId != closer {
+		var e *Token
+		if t.NdId == "[" && p.token.NdId == "," {
+			a = append(a, p.hole())
+			continue
+		} else if p.token.NdId == "..." {
+			e = p.rest(kind)
+			if p.token.NdId == "," {
+				p.token.Error("Rest element must be last.")
+			}
+		} else if t.NdId == "{" {
+			e = newProperty(p.token)
+			if p.shorthand() {
+				e.NdFirst = p.token.literal()
+			} else {
+				p.propertyName(e)
+				p.skip(":")
+			}
+			e.NdSecond = p.defaultValue(p.pattern(kind))
+		} else {
+			e = p.defaultValue(p.pattern(kind))
+		}
+		a = append(a, e)
+		if p.token.NdId != "," {
+			break
+		}
+		p.skip(",")
+	}
+	p.skip(closer)
+	t.NdList = a
+	t.NdArity = patternArity
+	return t
+}
+
+// hole parses an elided element of an array literal or pattern: the ',' that
+// follows it.
+func (p *Parser) hole() *Token {
+	t := p.token
+	p.skip(",")
+	t.NdArity = holeArity
+	return t
+}
+
+// rest parses a rest element or rest parameter: '...' followed by a binding
+// target.
+func (p *Parser) rest(kind string) *Token {
+	t := p.token
+	p.skip("...")
+	t.NdFirst = p.pattern(kind)
+	t.NdArity = unaryArity
+	return t
+}
+
+// defaultValue parses the optional `= expression` that may follow a binding
+// target, returning either the target itself or an '=' node holding the target
+// and its default.
+func (p *Parser) defaultValue(target *Token) *Token {
+	if p.token.NdId != "=" {
+		return target
+	}
+	t := p.token
+	p.skip("=")
+	t.NdFirst = target
+	t.NdSecond = p.expression(0)
+	t.NdArity = binaryArity
+	return t
+}
+
+// toPattern converts an expression that was parsed as the target of an
+// assignment into an assignment pattern. Array and object literals become
+// patterns, `=` elements become default values and spread elements become
+// rest elements.
+func (p *Parser) toPattern(x *Token) *Token {
+	if x.NdArity == patternArity {
+		return x
+	}
+	if x.NdArity == unaryArity && (x.NdId == "[" || x.NdId == "{") {
+		for i, e := range x.NdList {
+			if e.NdArity == holeArity {
+				continue
+			} else if e.NdId == "..." && e.NdArity == unaryArity {
+				if i != len(x.NdList)-1 {
+					e.Error("Rest element must be last.")
+				}
+				e.NdFirst = p.toPattern(e.NdFirst)
+			} else if e.NdArity == propertyArity {
+				if e.NdKind != "init" {
+					e.Error("Bad lvalue.")
+				}
+				e.NdSecond = p.toElement(e.NdSecond)
+			} else {
+				x.NdList[i] = p.toElement(e)
+			}
+		}
+		x.NdArity = patternArity
+		return x
+	}
+	p.assignable(x)
+	p.target(x)
+	return x
+}
+
+// toElement converts an element of an array or object literal into an element
+// of an assignment pattern. An assignment becomes a target with a default
+// value; its target was converted when the assignment was parsed. So does a
+// shorthand property initializer.
+func (p *Parser) toElement(e *Token) *Token {
+	if e.NdId == "=" && e.NdAssignment {
+		e.NdAssignment = false
+		return e
+	}
+	if p.uncover(e) {
+		e.NdFirst = p.toPattern(e.NdFirst)
+		return e
+	}
+	return p.toPattern(e)
+}
+
+// assignable reports an error if x can't be the target of an assignment.
+func (p *Parser) assignable(x *Token) {
+	if !possibleLvalue(x) {
+		x.Error("Bad lvalue.")
+	}
+	if x.NdArity == nameArity {
+		if d := p.scope.find(x.TkValue); d != nil && d.NdBinding == "const" {
+			x.Error("Assignment to constant.")
+		} else if d != nil && d.NdBinding == "import" {
+			x.Error("Assignment to an imported binding.")
+		}
+		// The name may yet turn out to be a constant declared later.
+		p.scope.assign(x)
+		if p.capture {
+			p.writes[x] = p.outerLoop()
+		}
+	}
+}
+
+// lookahead returns the raw token following the current one, or an empty
+// token at the end of input.
+func (p *Parser) lookahead() *Token {
+	if p.token.NdId == "(end)" || !p.fetch(p.tokenNumber) {
+		return &Token{}
+	}
+	return p.tokens[p.tokenNumber]
+}
+
+// spread parses an element of an array or object literal or an argument of a
+// call, which may be preceded by '...'.
+func (p *Parser) spread() *Token {
+	if p.token.NdId != "..." {
+		return p.expression(0)
+	}
+	t := p.token
+	p.skip("...")
+	t.NdFirst = p.expression(0)
+	t.NdArity = unaryArity
+	return t
+}
+
+// declaration parses the comma-separated bindings of a `let`, `const` or `var`
+// statement. It returns the initializations, if any.
+func (p *Parser) declaration(kind string) *Token {
+	a := []*Token{}
+	var n, t *Token
+	for {
+		n = p.pattern(kind)
+		if p.token.NdId != "=" {
+			if kind == "const" {
+				p.token.Error("Missing initializer in const declaration.")
+			}
+			if n.NdArity == patternArity {
+				p.token.Error("Expected '=' after destructuring pattern.")
+			}
+		}
+		if p.token.NdId == "=" {
+			t = p.token
+			p.skip("=")
+			t.NdFirst = n
+			t.NdSecond = p.expression(0)
+			t.NdArity = binaryArity
+			a = append(a, t)
+		}
+		if p.token.NdId != "," {
+			break
+		}
+		p.skip(",")
+	}
+	p.semicolon()
+	if len(a) == 0 {
+		return nil
+	} else if len(a) == 1 {
+		return a[0]
+	} else {
+		return &Token{
+			NdId:    kind,
+			NdArity: listArity,
+			NdList:  a,
+		}
+	}
+}
+
+// functionBody parses the parameter list and body of a function whose scope
+// has already been opened, and closes that scope.
+func (p *Parser) functionBody(this *Token) *Token {
+	p.skip("(")
+	this.NdList = p.parameters()
+	p.skip(")")
+	p.skip("{")
+	this.NdSecond = p.statements()
+	p.skip("}")
+	this.NdArity = functionArity
+	this.NdGenerator = p.scope.generator
+	this.NdAsync = p.scope.async
+	p.popFunctionScope(this)
+	return this
 }
 
-func itself(this *Token) *Token {
-	//DEBUG fmt.Printf("itself: %v\n", this)
+// classBody parses the optional `extends` clause and the member list of a
+// class, in a scope that has already been opened, and closes that scope.
+// Each member is a property node whose first child is the key and whose
+// second child is the method.
+func (p *Parser) classBody(this *Token) *Token {
+	if p.token.NdId == "extends" {
+		p.reserveInScope(p.token)
+		p.skip("extends")
+		this.NdFirst = p.expression(70)
+	}
+	p.skip("{")
+	a := []*Token{}
+	hasConstructor := false
+	for p.token.NdId != "}" {
+		if p.token.NdId == ";" {
+			p.skip(";")
+			continue
+		}
+		m := newProperty(p.token)
+		m.NdKind = "method"
+		if p.token.TkValue == "static" && p.lookahead().TkValue != "(" {
+			m.NdStatic = true
+			p.advance()
+		}
+		async, generator := p.modifiers()
+		if !async && !generator && p.accessor() {
+			m.NdKind = p.token.TkValue
+			p.advance()
+		}
+		p.propertyName(m)
+		if !m.NdComputed && m.NdFirst.TkValue == "constructor" && !m.NdStatic {
+			if m.NdKind != "method" {
+				m.NdFirst.Error("Constructor can't be an accessor.")
+			}
+			if async || generator {
+				m.NdFirst.Error("Constructor can't be async or a generator.")
+			}
+			if hasConstructor {
+				m.NdFirst.Error("Duplicate constructor.")
+			}
+			hasConstructor = true
+			m.NdKind = "constructor"
+		}
+		m.NdSecond = p.method(m, async, generator)
+		a = append(a, m)
+	}
+	p.skip("}")
+	this.NdList = a
+	this.NdArity = classArity
+	p.popScope()
 	return this
 }
 
-func (p *Parser) initializeSymbolTable() {
+// newProperty returns a property node positioned at the token at. Its first
+// child will be the key and its second the value.
+func newProperty(at *Token) *Token {
+	return &Token{
+		NdId:     "property",
+		NdArity:  propertyArity,
+		NdKind:   "init",
+		TkLine:   at.TkLine,
+		TkColumn: at.TkColumn,
+	}
+}
+
+// property parses a member of an object literal: `key: value`, a shorthand
+// `name` (which may have a default, for use as a pattern), a method, or a
+// `get` or `set` accessor.
+func (p *Parser) property() *Token {
+	m := newProperty(p.token)
+	if p.shorthand() {
+		m.NdFirst = p.token.literal()
+		if p.lookahead().TkValue == "=" {
+			m.NdSecond = p.cover()
+		} else {
+			m.NdSecond = p.expression(0)
+		}
+		return m
+	}
+	async, generator := p.modifiers()
+	if !async && !generator && p.accessor() {
+		m.NdKind = p.token.TkValue
+		p.advance()
+	}
+	p.propertyName(m)
+	if async || generator || m.NdKind != "init" || p.token.NdId == "(" {
+		if m.NdKind == "init" {
+			m.NdKind = "method"
+		}
+		m.NdSecond = p.method(m, async, generator)
+	} else {
+		p.skip(":")
+		m.NdSecond = p.expression(0)
+	}
+	return m
+}
+
+// shorthand reports whether the current token is a name that stands for both
+// the key and the value of a property, as in `{a, b}`.
+func (p *Parser) shorthand() bool {
+	if p.token.NdArity != nameArity {
+		return false
+	}
+	next := p.lookahead().TkValue
+	return next == "," || next == "}" || next == "="
+}
+
+// cover parses a shorthand property with an initializer, `{a = 1}`. That is
+// only valid in an object literal that becomes an assignment pattern, where
+// it is a default value; until then the initializer is held in p.covers.
+func (p *Parser) cover() *Token {
+	n := p.expression(10) // the name, but not the '='
+	d := p.token
+	p.skip("=")
+	d.NdFirst = n
+	d.NdSecond = p.expression(0)
+	d.NdArity = binaryArity
+	p.covers = append(p.covers, d)
+	return d
+}
+
+// uncover reports whether e is a shorthand property initializer, and if so
+// accepts it as a default value.
+func (p *Parser) uncover(e *Token) bool {
+	for i := len(p.covers) - 1; i >= 0; i-- {
+		if p.covers[i] == e {
+			p.covers[i] = nil
+			return true
+		}
+	}
+	return false
+}
+
+// accessor reports whether the current token is a `get` or `set` that
+// introduces an accessor, rather than the name of a property.
+func (p *Parser) accessor() bool {
+	v := p.token.TkValue
+	if p.token.TkType != Name || (v != "get" && v != "set") {
+		return false
+	}
+	switch p.lookahead().TkValue {
+	case "(", ",", ":", "}", "=", ";":
+		return false
+	}
+	return true
+}
+
+// modifiers parses the `async` and '*' that may precede the name of a
+// method, reporting which were present.
+func (p *Parser) modifiers() (async, generator bool) {
+	if p.token.TkType == Name && p.token.TkValue == "async" {
+		switch next := p.lookahead(); next.TkValue {
+		case "(", ",", ":", "}", "=", ";":
+		default:
+			if !next.TkNewline {
+				async = true
+				p.advance()
+			}
+		}
+	}
+	if p.token.NdId == "*" {
+		generator = true
+		p.advance()
+	}
+	return
+}
+
+// propertyName parses the key of the property m: a name (including a reserved
+// word), a literal, or a computed key in brackets.
+func (p *Parser) propertyName(m *Token) {
+	if p.token.NdId == "[" {
+		p.skip("[")
+		m.NdFirst = p.expression(0)
+		m.NdComputed = true
+		p.skip("]")
+		return
+	}
+	key := p.token
+	if key.TkType != Name && key.TkType != Literal {
+		key.Error("Bad property name.")
+	}
+	m.NdFirst = key.literal()
+	p.advance()
+}
+
+// method parses the parameters and body of the method, getter or setter m,
+// returning a function node.
+func (p *Parser) method(m *Token, async, generator bool) *Token {
+	f := &Token{
+		NdId:     "function",
+		TkValue:  "function",
+		TkType:   Name,
+		TkLine:   p.token.TkLine,
+		TkColumn: p.token.TkColumn,
+	}
+	if !m.NdComputed {
+		f.NdName = m.NdFirst.TkValue
+	}
+	p.newFunctionScope()
+	p.scope.async = async
+	p.scope.generator = generator
+	p.functionBody(f)
+	if m.NdKind == "get" && len(f.NdList) != 0 {
+		f.Error("Getter must not have parameters.")
+	} else if m.NdKind == "set" && len(f.NdList) != 1 {
+		f.Error("Setter must have exactly one parameter.")
+	}
+	return f
+}
+
+// template parses a template literal whose first piece is this. The result
+// is a template node whose list alternates between the literal text of each
+// piece and the expressions substituted between them.
+func (p *Parser) template(this *Token) *Token {
+	piece := this
+	a := []*Token{templateText(piece)}
+	for piece.TkType == TemplateHead {
+		a = append(a, p.expression(0))
+		piece = p.token
+		if piece.NdId != "(template part)" {
+			piece.Error("Expected '}' after template substitution.")
+		}
+		p.advance()
+		a = append(a, templateText(piece))
+	}
+	return &Token{
+		TkType:   Template,
+		TkValue:  "`",
+		TkLine:   this.TkLine,
+		TkColumn: this.TkColumn,
+		NdId:     "(template)",
+		NdArity:  templateArity,
+		NdList:   a,
+	}
+}
+
+// templateText returns a literal holding the raw text of a template piece,
+// without its delimiters.
+func templateText(piece *Token) *Token {
+	v := piece.TkValue[1:]
+	if piece.TkType == TemplateHead {
+		v = v[:len(v)-2]
+	} else {
+		v = v[:len(v)-1]
+	}
+	return &Token{
+		TkType:   Literal,
+		TkValue:  v,
+		TkLine:   piece.TkLine,
+		TkColumn: piece.TkColumn,
+		NdId:     "(literal)",
+		NdArity:  literalArity,
+	}
+}
+
+// arrowAhead reports whether the parenthesized list that starts at the current
+// token is the parameter list of an arrow function. It scans forward through
+// the raw tokens to the matching ')' and checks whether '=>' follows it.
+func (p *Parser) arrowAhead() bool {
+	if p.token.NdId == "(end)" {
+		return false
+	}
+	depth := 0
+	for i := p.tokenNumber - 1; p.fetch(i); i++ {
+		t := p.tokens[i]
+		if t.TkType != Punctuator {
+			continue
+		}
+		switch t.TkValue {
+		case "(", "[", "{":
+			depth += 1
+		case ")", "]", "}":
+			if depth == 0 {
+				return t.TkValue == ")" && p.fetch(i+1) &&
+					p.tokens[i+1].TkValue == "=>"
+			}
+			depth -= 1
+		}
+	}
+	return false
+}
+
+// arrowBody parses the '=>' and the body of an arrow function whose
+// parameters have already been defined in a new scope. The body is either a
+// block or a single (concise) expression.
+func (p *Parser) arrowBody(params []*Token) *Token {
+	this := p.token
+	p.skip("=>")
+	this.NdList = params
+	if p.token.NdId == "{" {
+		p.skip("{")
+		this.NdSecond = p.statements()
+		p.skip("}")
+	} else {
+		this.NdSecond = p.expression(9)
+		this.NdConcise = true
+	}
+	this.NdArity = functionArity
+	this.NdAsync = p.scope.async
+	p.popFunctionScope(this)
+	return this
+}
+
+// asyncAhead reports whether t, which has just been consumed, is an `async`
+// that begins an async function or async arrow function rather than a name.
+func (p *Parser) asyncAhead(t *Token) bool {
+	if t.NdArity != nameArity || t.TkValue != "async" || p.token.TkNewline {
+		return false
+	}
+	switch {
+	case p.token.NdId == "function":
+		return true
+	case p.token.NdId == "(":
+		// Both `async (a) => a` and a call of a function named async.
+		return true
+	case p.token.NdArity == nameArity:
+		return p.lookahead().TkValue == "=>"
+	}
+	return false
+}
+
+// async parses what follows an `async` keyword: a function expression, an
+// arrow function, or -- if the parentheses turn out not to be an arrow's
+// parameters -- a call of a function named async.
+func (p *Parser) async(t *Token) *Token {
+	switch p.token.NdId {
+	case "function":
+		f := p.token
+		p.advance()
+		return p.function(f, true)
+	case "(":
+		paren := p.token
+		p.advance()
+		if !p.arrowAhead() {
+			return paren.TkLed(p, paren, t.TkNud(p, t))
+		}
+		p.newFunctionScope()
+		p.scope.async = true
+		a := p.parameters()
+		p.skip(")")
+		return p.arrowBody(a)
+	}
+	param := p.token
+	p.advance()
+	p.newFunctionScope()
+	p.scope.async = true
+	p.scope.define(param, "param")
+	return p.arrowBody([]*Token{param})
+}
+
+// function parses a function expression or declaration after the `function`
+// keyword this: an optional '*', an optional name, the parameters and the
+// body.
+func (p *Parser) function(this *Token, async bool) *Token {
+	p.newFunctionScope()
+	p.scope.async = async
+	if p.token.NdId == "*" {
+		p.scope.generator = true
+		p.advance()
+	}
+	if p.token.NdArity == nameArity {
+		p.scope.define(p.token, "function")
+		this.NdName = p.token.TkValue
+		p.advance()
+	}
+	return p.functionBody(this)
+}
+
+func itself(p *Parser, this *Token) *Token {
+	return this
+}
+
+// useName is the nud of a name defined in scope. The use is linked to its
+// declaration when the scope is popped, because a later `var` in an inner
+// function, or a later `let` in an inner block, may be the one it refers to.
+func useName(p *Parser, this *Token) *Token {
+	p.scope.pending = append(p.scope.pending, &reference{name: this, declared: true})
+	return this
+}
+
+// initializeCoreSymbols defines the symbols that every grammar needs: those
+// for the kinds of token the lexer produces, and the ';' and block statement
+// that programs are built from.
+func (p *Parser) initializeCoreSymbols() {
 	p.symbol_table = map[string]*Token{}
 	p.symbol("(end)", -1)
-	p.symbol("(name)", -1)
-	p.symbol(":", -1)
+	p.symbol("(template part)", -1)
+	name := p.symbol("(name)", -1)
+	name.NdArity = nameArity
+	name.TkNud = func(p *Parser, this *Token) *Token {
+		// Not yet defined: a later `var` may declare it.
+		p.scope.pending = append(p.scope.pending, &reference{name: this})
+		return this
+	}
+	p.describe("(name)").nud = form{arity: "name", syntax: "name"}
 	p.symbol(";", -1)
+	p.symbol("}", -1)
+
+	p.symbol("(literal)", -1).TkNud = itself
+	p.describe("(literal)").nud = form{arity: "literal", syntax: "number | string"}
+
+	p.symbol("(regexp)", -1).TkNud = func(p *Parser, this *Token) *Token {
+		if err := checkRegexp(this.TkValue); err != nil {
+			this.Error(err.Error())
+		}
+		this.NdArity = literalArity
+		return this
+	}
+	p.describe("(regexp)").nud = form{arity: "literal", syntax: "regexp"}
+
+	p.prefix("(template)", func(p *Parser, this *Token) *Token {
+		return p.template(this)
+	})
+	p.describe("(template)").nud = form{arity: "template", syntax: "template"}
+
+	p.stmt("{", func(p *Parser, this *Token) *Token {
+		p.newScope()
+		a := p.statements()
+		p.skip("}")
+		p.popScope()
+		return a
+	})
+}
+
+func (p *Parser) initializeSymbolTable() {
+	p.initializeCoreSymbols()
+	p.symbol(":", -1)
 	p.symbol(")", -1)
 	p.symbol("]", -1)
-	p.symbol("}", -1)
 	p.symbol(",", -1)
+	p.symbol("=>", -1)
+	p.symbol("...", -1)
 	p.symbol("else", -1)
+	p.symbol("extends", -1)
 
 	p.constant("true", "#t")
 	p.constant("false", "#f")
@@ -293,20 +1366,34 @@ func (p *Parser) initializeSymbolTable() {
 	//p.constant("Object", {});
 	//p.constant("Array", []);
 
-	p.symbol("(literal)", -1).TkNud = itself
+	// A tagged template, such as tag`text ${value}`.
+	p.infix("(template)", 80, func(p *Parser, this, left *Token) *Token {
+		t := p.template(this)
+		t.NdFirst = left
+		return t
+	})
+	p.describe("(template)").led = form{arity: "template", syntax: "expression template"}
 
-	p.symbol("this", -1).TkNud = func(this *Token) *Token {
-		//DEBUG fmt.Printf("this: %v\n", this)
+	p.symbol("this", -1).TkNud = func(p *Parser, this *Token) *Token {
 		p.reserveInScope(this)
 		this.NdArity = thisArity
 		return this
 	}
+	p.describe("this").nud = form{arity: "this", syntax: `"this"`}
+
+	p.symbol("super", -1).TkNud = func(p *Parser, this *Token) *Token {
+		p.reserveInScope(this)
+		this.NdArity = superArity
+		return this
+	}
+	p.describe("super").nud = form{arity: "super", syntax: `"super"`}
 
 	p.assignment("=")
 	p.assignment("+=")
 	p.assignment("-=")
+	p.assignment("??=")
 
-	p.infix("?", 20, func(this, left *Token) *Token {
+	p.infix("?", 20, func(p *Parser, this, left *Token) *Token {
 		this.NdFirst = left
 		this.NdSecond = p.expression(0)
 		p.skip(":")
@@ -314,9 +1401,12 @@ func (p *Parser) initializeSymbolTable() {
 		this.NdArity = ternaryArity
 		return this
 	})
+	p.describe("?").led = form{arity: "ternary", syntax: `expression "?" expression ":" expression`}
 
-	p.infixr("&&", 30, nil)
-	p.infixr("||", 30, nil)
+	p.infixr("&&", 30, p.logical(29))
+	p.infixr("||", 30, p.logical(29))
+	p.infix("??", 30, p.logical(30))
+	p.allow(ProblemAssociativity, "&&", "??", "||") // logical keeps them from mixing
 
 	p.infixr("===", 40, nil)
 	p.infixr("!==", 40, nil)
@@ -331,7 +1421,7 @@ func (p *Parser) initializeSymbolTable() {
 	p.infix("*", 60, nil)
 	p.infix("/", 60, nil)
 
-	p.infix(".", 80, func(this, left *Token) *Token {
+	p.infix(".", 80, func(p *Parser, this, left *Token) *Token {
 		this.NdFirst = left
 		if p.token.NdArity != nameArity {
 			p.token.Error("Expected a property name.")
@@ -343,7 +1433,7 @@ func (p *Parser) initializeSymbolTable() {
 		return this
 	})
 
-	p.infix("[", 80, func(this, left *Token) *Token {
+	p.infix("[", 80, func(p *Parser, this, left *Token) *Token {
 		this.NdFirst = left
 		this.NdSecond = p.expression(0)
 		this.NdArity = binaryArity
@@ -351,16 +1441,36 @@ func (p *Parser) initializeSymbolTable() {
 		return this
 	})
 
-	p.infix("(", 80, func(this, left *Token) *Token {
+	// Optional chaining: a?.b, a?.[b] and a?.(b) become the same nodes as
+	// a.b, a[b] and a(b), marked as optional.
+	p.infix("?.", 80, func(p *Parser, this, left *Token) *Token {
+		var t *Token
+		if p.token.NdId == "[" || p.token.NdId == "(" {
+			t = p.token
+			p.advance()
+			t = t.TkLed(p, t, left)
+		} else {
+			this.NdId = "."
+			t = p.symbol_table["."].TkLed(p, this, left)
+		}
+		t.NdOptional = true
+		return t
+	})
+	p.describe("?.").led = form{arity: "binary or ternary",
+		syntax: `expression "?." (name | "[" expression "]" | "(" arguments ")")`}
+
+	p.infix("(", 80, func(p *Parser, this, left *Token) *Token {
 		if left.NdId == "." || left.NdId == "[" {
 			this.NdArity = ternaryArity
 			this.NdFirst = left.NdFirst
 			this.NdSecond = left.NdSecond
+			this.NdOptional = left.NdOptional
 		} else {
 			this.NdArity = binaryArity
 			this.NdFirst = left
-			if (left.NdArity != unaryArity || left.NdId != "function") && //  'ƒ' for "function"?
-				left.NdArity != nameArity && left.NdId != "(" &&
+			if left.NdArity != functionArity && //  'ƒ' for "function"?
+				left.NdArity != nameArity && left.NdArity != superArity &&
+				left.NdId != "(" &&
 				left.NdId != "&&" && left.NdId != "||" && // '∧' for "&&" and '∨' for "||"?
 				left.NdId != "?" {
 				left.Error("Expected a variable name.")
@@ -369,7 +1479,7 @@ func (p *Parser) initializeSymbolTable() {
 		this.NdList = []*Token{}
 		if p.token.NdId != ")" {
 			for {
-				this.NdList = append(this.NdList, p.expression(0))
+				this.NdList = append(this.NdList, p.spread())
 				if p.token.NdId != "," {
 					break
 				}
@@ -379,63 +1489,74 @@ func (p *Parser) initializeSymbolTable() {
 		p.skip(")")
 		return this
 	})
+	p.describe("(").led = form{arity: "binary or ternary", syntax: `expression "(" arguments ")"`}
+	p.allow(ProblemArity, "(", "(template)", ".", "?.", "[") // calls and member accesses
 
 	p.prefix("!", nil)
 	p.prefix("-", nil)
 	p.prefix("typeof", nil)
+	p.prefix("await", nil)
 
-	p.prefix("(", func(this *Token) *Token {
+	// `yield` has an optional operand, which can't begin on a new line, and
+	// `yield*` delegates to another iterable.
+	p.prefix("yield", func(p *Parser, this *Token) *Token {
+		p.reserveInScope(this)
+		this.NdArity = unaryArity
+		if p.token.NdId == "*" && !p.token.TkNewline {
+			p.skip("*")
+			this.NdId = "yield*"
+			this.NdFirst = p.expression(9)
+			return this
+		}
+		switch p.token.NdId {
+		case ")", "]", "}", ",", ";", ":", "(end)":
+			return this
+		}
+		if !p.token.TkNewline {
+			this.NdFirst = p.expression(9)
+		}
+		return this
+	})
+
+	p.prefix("(", func(p *Parser, this *Token) *Token {
+		if p.arrowAhead() {
+			p.newFunctionScope()
+			a := p.parameters()
+			p.skip(")")
+			return p.arrowBody(a)
+		}
 		e := p.expression(0)
 		p.skip(")")
+		e.NdParenthesized = true
 		return e
 	})
 
-	p.prefix("function", func(this *Token) *Token {
-		// fmt.Printf("consumed `function`; current token is %v\n", p.token)
-		a := []*Token{}
+	p.prefix("function", func(p *Parser, this *Token) *Token {
+		return p.function(this, false)
+	})
+
+	p.prefix("class", func(p *Parser, this *Token) *Token {
 		p.newScope()
 		if p.token.NdArity == nameArity {
-			p.scope.define(p.token)
+			p.scope.define(p.token, "class")
 			this.NdName = p.token.TkValue
-			// fmt.Printf("after `function`, consumed name %v\n", p.token)
 			p.advance()
 		}
-		// fmt.Printf("after `function [name]`, looking for `('; current token is  %v\n", p.token)
-		p.skip("(")
-		if p.token.NdId != ")" {
-			for {
-				if p.token.NdArity != nameArity {
-					p.token.Error("Expected a parameter name.")
-				}
-				p.scope.define(p.token)
-				a = append(a, p.token)
-				p.advance()
-				if p.token.NdId != "," {
-					break
-				}
-				p.skip(",")
-			}
-		}
-		this.NdList = a
-		p.skip(")")
-		p.skip("{")
-		this.NdSecond = p.statements()
-		p.skip("}")
-		this.NdArity = functionArity
-		p.popScope()
-		return this
+		return p.classBody(this)
 	})
 
-	p.prefix("[", func(this *Token) *Token {
+	p.prefix("[", func(p *Parser, this *Token) *Token {
 		a := []*Token{}
-		if p.token.NdId != "]" {
-			for {
-				a = append(a, p.expression(0))
-				if p.token.NdId != "," {
-					break
-				}
-				p.skip(",")
+		for p.token.NdId != "]" {
+			if p.token.NdId == "," {
+				a = append(a, p.hole())
+				continue
+			}
+			a = append(a, p.spread())
+			if p.token.NdId != "," {
+				break
 			}
+			p.skip(",")
 		}
 		p.skip("]")
 		this.NdList = a
@@ -443,25 +1564,18 @@ func (p *Parser) initializeSymbolTable() {
 		return this
 	})
 
-	p.prefix("{", func(this *Token) *Token {
+	p.prefix("{", func(p *Parser, this *Token) *Token {
 		a := []*Token{}
-		var n, v *Token
-		if p.token.NdId != "}" {
-			for {
-				n = p.token
-				if n.NdArity != nameArity && n.NdArity != literalArity {
-					p.token.Error("Bad property name.")
-				}
-				p.advance()
-				p.skip(":")
-				v = p.expression(0)
-				v.NdKey = n.TkValue
-				a = append(a, v)
-				if p.token.NdId != "," {
-					break
-				}
-				p.skip(",")
+		for p.token.NdId != "}" {
+			if p.token.NdId == "..." {
+				a = append(a, p.spread())
+			} else {
+				a = append(a, p.property())
+			}
+			if p.token.NdId != "," {
+				break
 			}
+			p.skip(",")
 		}
 		p.skip("}")
 		this.NdList = a
@@ -469,52 +1583,35 @@ func (p *Parser) initializeSymbolTable() {
 		return this
 	})
 
-	p.stmt("{", func(this *Token) *Token {
+	p.stmt("class", func(p *Parser, this *Token) *Token {
+		if p.token.NdArity != nameArity {
+			p.token.Error("Expected a class name.")
+		}
+		p.scope.define(p.token, "class")
+		this.NdName = p.token.TkValue
+		p.advance()
 		p.newScope()
-		a := p.statements()
-		p.skip("}")
-		p.popScope()
-		return a
+		return p.classBody(this)
 	})
 
-	p.stmt("let", func(this *Token) *Token {
-		a := []*Token{}
-		var n, t *Token
-		for {
-			n = p.token
-			if n.NdArity != nameArity {
-				n.Error("Expected a new variable name.")
-			}
-			p.scope.define(n)
-			p.advance()
-			if p.token.NdId == "=" {
-				t = p.token
-				p.skip("=")
-				t.NdFirst = n
-				t.NdSecond = p.expression(0)
-				t.NdArity = binaryArity
-				a = append(a, t)
-			}
-			if p.token.NdId != "," {
-				break
-			}
-			p.skip(",")
-		}
-		p.skip(";")
-		if len(a) == 0 {
-			return nil
-		} else if len(a) == 1 {
-			return a[0]
-		} else {
-			return &Token{
-				NdId:    "let",
-				NdArity: listArity,
-				NdList:  a,
-			}
-		}
+	// A statement that begins with `{` is a block, and one that begins with
+	// `class` is a class declaration; elsewhere they are expressions.
+	p.allow(ProblemNudAndStd, "{")
+	p.allow(ProblemNudAndStd, "class")
+
+	p.stmt("let", func(p *Parser, this *Token) *Token {
+		return p.declaration("let")
+	})
+
+	p.stmt("const", func(p *Parser, this *Token) *Token {
+		return p.declaration("const")
 	})
 
-	p.stmt("if", func(this *Token) *Token {
+	p.stmt("var", func(p *Parser, this *Token) *Token {
+		return p.declaration("var")
+	})
+
+	p.stmt("if", func(p *Parser, this *Token) *Token {
 		p.skip("(")
 		this.NdFirst = p.expression(0)
 		p.skip(")")
@@ -534,11 +1631,11 @@ func (p *Parser) initializeSymbolTable() {
 		return this
 	})
 
-	p.stmt("return", func(this *Token) *Token {
-		if p.token.NdId != ";" {
+	p.stmt("return", func(p *Parser, this *Token) *Token {
+		if !p.statementEnds() {
 			this.NdFirst = p.expression(0)
 		}
-		p.skip(";")
+		p.semicolon()
 		if p.token.NdId != "}" {
 			p.token.Error("Unreachable statement.")
 		}
@@ -546,8 +1643,8 @@ func (p *Parser) initializeSymbolTable() {
 		return this
 	})
 
-	p.stmt("break", func(this *Token) *Token {
-		p.skip(";")
+	p.stmt("break", func(p *Parser, this *Token) *Token {
+		p.semicolon()
 		if p.token.NdId != "}" {
 			p.token.Error("Unreachable statement.")
 		}
@@ -555,12 +1652,95 @@ func (p *Parser) initializeSymbolTable() {
 		return this
 	})
 
-	p.stmt("while", func(this *Token) *Token {
+	p.stmt("continue", func(p *Parser, this *Token) *Token {
+		p.semicolon()
+		if p.token.NdId != "}" {
+			p.token.Error("Unreachable statement.")
+		}
+		this.NdArity = statementArity
+		return this
+	})
+
+	p.stmt("throw", func(p *Parser, this *Token) *Token {
+		if p.token.TkNewline {
+			p.token.Error("Illegal newline after throw.")
+		}
+		this.NdFirst = p.expression(0)
+		p.semicolon()
+		if p.token.NdId != "}" {
+			p.token.Error("Unreachable statement.")
+		}
+		this.NdArity = statementArity
+		return this
+	})
+
+	p.stmt("while", func(p *Parser, this *Token) *Token {
+		p.loops = append(p.loops, this)
 		p.skip("(")
 		this.NdFirst = p.expression(0)
 		p.skip(")")
 		this.NdSecond = p.block()
 		this.NdArity = statementArity
+		p.loops = p.loops[:len(p.loops)-1]
+		return this
+	})
+
+	// An import's list holds a property node for each binding, whose key is
+	// the imported name and whose value is the local name. Its second child
+	// is the module specifier.
+	p.stmt("import", func(p *Parser, this *Token) *Token {
+		p.topLevel(this)
+		var source string
+		if p.token.TkType == Literal {
+			this.NdSecond, source = p.specifier()
+			p.module.Imports = append(p.module.Imports, Import{Source: source})
+		} else {
+			this.NdList = p.importClause()
+			this.NdSecond, source = p.moduleSource()
+		}
+		for _, m := range this.NdList {
+			p.module.Imports = append(p.module.Imports, Import{
+				Source: source,
+				Name:   m.NdFirst.TkValue,
+				Local:  m.NdSecond.TkValue,
+			})
+		}
+		p.semicolon()
+		this.NdArity = statementArity
+		return this
+	})
+
+	// An export's first child is the declaration or `default` expression it
+	// exports; the list of an `export {a, b as c}` holds a property node for
+	// each name, whose key is the exported name and whose value is the local
+	// name.
+	p.stmt("export", func(p *Parser, this *Token) *Token {
+		p.topLevel(this)
+		this.NdArity = statementArity
+		switch p.token.NdId {
+		case "{":
+			this.NdList = p.exportList()
+			p.semicolon()
+			for _, m := range this.NdList {
+				p.export(m, m.NdFirst.TkValue, m.NdSecond.TkValue)
+			}
+		case "let", "const", "var", "class":
+			n := len(p.scope.declared)
+			this.NdFirst = p.statement()
+			for _, d := range p.scope.declared[n:] {
+				p.export(d, d.TkValue, d.TkValue)
+				p.exported(d)
+			}
+		default:
+			if p.token.TkType != Name || p.token.TkValue != "default" {
+				p.token.Error("Expected a declaration, 'default' or '{' after 'export'.")
+			}
+			at := p.token
+			p.advance()
+			this.NdFirst = p.expression(0)
+			p.semicolon()
+			p.export(at, "default", "")
+		}
 		return this
 	})
 }
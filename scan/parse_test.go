@@ -1,6 +1,7 @@
 package scan
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -85,8 +86,8 @@ func TestDoubleDeclaration(t *testing.T) {
 			t.Errorf("expected no tree returned; got %v", tree)
 		}
 		if r := recover(); r != nil {
-			s, ok := r.(string)
-			if !ok || !strings.Contains(s, "Already defined") {
+			e, ok := r.(*SyntaxError)
+			if !ok || !strings.Contains(e.Message, "Already defined") {
 				t.Errorf("Expected `Already defined`; got %s", r)
 			}
 		} else {
@@ -128,3 +129,794 @@ func TestIf(t *testing.T) {
 		t.Errorf("expected if's alternative to be 'z()'; got %v", a)
 	}
 }
+
+func TestArrowFunction(t *testing.T) {
+	defer recoverFromPanic(t)
+	source := `let f = (a, b) => a + b, g = x => { return x; }, h = () => 42;`
+	tree := parseString(source)
+	if tree.NdArity != listArity || len(tree.NdList) != 3 {
+		t.Fatalf("expected three initializations; got %v\n", tree)
+	}
+
+	a := tree.NdList[0].NdSecond
+	if a.NdId != "=>" || a.NdArity != functionArity || !a.NdConcise {
+		t.Errorf("expected concise arrow function; got %v", a)
+	}
+	if len(a.NdList) != 2 || a.NdList[0].TkValue != "a" || a.NdList[1].TkValue != "b" {
+		t.Errorf("expected parameters `a, b`; got %v", a.NdList)
+	}
+	if a.NdSecond.TkValue != "+" || a.NdSecond.NdFirst.TkValue != "a" {
+		t.Errorf("expected body `a + b`; got %v", a.NdSecond)
+	}
+
+	a = tree.NdList[1].NdSecond
+	if a.NdId != "=>" || a.NdConcise || len(a.NdList) != 1 {
+		t.Errorf("expected single-parameter arrow function with block body; got %v", a)
+	}
+	if a.NdSecond.TkValue != "return" {
+		t.Errorf("expected body `return x;`; got %v", a.NdSecond)
+	}
+
+	a = tree.NdList[2].NdSecond
+	if a.NdId != "=>" || len(a.NdList) != 0 || a.NdSecond.TkValue != "42" {
+		t.Errorf("expected parameterless arrow function; got %v", a)
+	}
+}
+
+func TestParenthesizedExpression(t *testing.T) {
+	defer recoverFromPanic(t)
+	source := `let a, b; a = (b) * 2;`
+	tree := parseString(source)
+	if tree.TkValue != "=" || tree.NdSecond.TkValue != "*" ||
+		tree.NdSecond.NdFirst.TkValue != "b" {
+		t.Errorf("expected `a = b * 2`; got %v\n", tree)
+	}
+}
+
+func TestDestructuringLet(t *testing.T) {
+	defer recoverFromPanic(t)
+	source := `let o, [a, b = 2, ...c] = o, {d, e: [f], g = 3, ...h} = o;
+f = a + b + d + g;`
+	tree := parseString(source)
+	if tree.NdArity != listArity || len(tree.NdList) != 2 {
+		t.Fatalf("expected a declaration and an assignment; got %v\n", tree)
+	}
+	tree = tree.NdList[0]
+	if tree.NdId != "let" || len(tree.NdList) != 2 {
+		t.Fatalf("expected two initializations; got %v\n", tree)
+	}
+
+	a := tree.NdList[0].NdFirst
+	if a.NdId != "[" || a.NdArity != patternArity || len(a.NdList) != 3 {
+		t.Fatalf("expected array pattern; got %v", a)
+	}
+	if a.NdList[1].NdId != "=" || a.NdList[1].NdFirst.TkValue != "b" {
+		t.Errorf("expected `b = 2`; got %v", a.NdList[1])
+	}
+	if a.NdList[2].NdId != "..." || a.NdList[2].NdFirst.TkValue != "c" {
+		t.Errorf("expected `...c`; got %v", a.NdList[2])
+	}
+
+	a = tree.NdList[1].NdFirst
+	if a.NdId != "{" || a.NdArity != patternArity || len(a.NdList) != 4 {
+		t.Fatalf("expected object pattern; got %v", a)
+	}
+	if b := a.NdList[0]; b.NdArity != propertyArity || b.NdFirst.TkValue != "d" ||
+		b.NdFirst.NdArity != literalArity || b.NdSecond.TkValue != "d" {
+		t.Errorf("expected shorthand `d`; got %v", b)
+	}
+	if b := a.NdList[1]; b.NdFirst.TkValue != "e" || b.NdSecond.NdArity != patternArity {
+		t.Errorf("expected `e: [f]`; got %v", b)
+	}
+	if b := a.NdList[2]; b.NdFirst.TkValue != "g" || b.NdSecond.NdId != "=" {
+		t.Errorf("expected `g = 3`; got %v", b)
+	}
+}
+
+func TestDestructuringParameters(t *testing.T) {
+	defer recoverFromPanic(t)
+	source := `let f = function ([a, b], {c}) { return a; }, g = ([x, y]) => x + y;`
+	tree := parseString(source)
+	a := tree.NdList[0].NdSecond
+	if len(a.NdList) != 2 || a.NdList[0].NdArity != patternArity ||
+		a.NdList[1].NdArity != patternArity {
+		t.Errorf("expected two pattern parameters; got %v", a)
+	}
+	a = tree.NdList[1].NdSecond
+	if a.NdId != "=>" || len(a.NdList) != 1 || a.NdList[0].NdArity != patternArity {
+		t.Errorf("expected arrow function with pattern parameter; got %v", a)
+	}
+}
+
+func TestDestructuringAssignment(t *testing.T) {
+	defer recoverFromPanic(t)
+	source := `let a, b, o; [a, [b], ...o] = [b, a]; ({x: a, y: b = 1} = o);`
+	tree := parseString(source)
+	a := tree.NdList[0]
+	if !a.NdAssignment || a.NdFirst.NdArity != patternArity || len(a.NdFirst.NdList) != 3 {
+		t.Fatalf("expected array pattern assignment; got %v", a)
+	}
+	if a.NdFirst.NdList[1].NdArity != patternArity {
+		t.Errorf("expected nested pattern; got %v", a.NdFirst.NdList[1])
+	}
+	a = tree.NdList[1]
+	if !a.NdAssignment || a.NdFirst.NdArity != patternArity {
+		t.Fatalf("expected object pattern assignment; got %v", a)
+	}
+	if b := a.NdFirst.NdList[1].NdSecond; b.NdId != "=" || b.NdAssignment ||
+		a.NdFirst.NdList[1].NdFirst.TkValue != "y" {
+		t.Errorf("expected default value for `y`; got %v", b)
+	}
+}
+
+func expectSyntaxError(t *testing.T, source, message string) {
+	t.Helper()
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*SyntaxError)
+			if !ok || !strings.Contains(e.Message, message) {
+				t.Errorf("%s: expected `%s`; got %v", source, message, r)
+			}
+		} else {
+			t.Errorf("%s: expected a panic to occur", source)
+		}
+	}()
+	parseString(source)
+}
+
+func TestBadPatterns(t *testing.T) {
+	expectSyntaxError(t, `let [a, ...b, c] = d;`, "Rest element must be last.")
+	expectSyntaxError(t, `let a; [...a, a] = a;`, "Rest element must be last.")
+	expectSyntaxError(t, `let a; [a + 1] = a;`, "Bad lvalue.")
+	expectSyntaxError(t, `let [a];`, "Expected '=' after destructuring pattern.")
+}
+
+func TestArrayHoles(t *testing.T) {
+	defer recoverFromPanic(t)
+	source := `let o, [, a, , ...b] = o, c = [a, , b, ,], d = [,];
+[a, , b] = c;`
+	tree := parseString(source)
+	holes := func(x *Token) string {
+		s := ""
+		for _, e := range x.NdList {
+			if e.NdArity == holeArity {
+				s += "_"
+			} else {
+				s += "x"
+			}
+		}
+		return s
+	}
+	decls := tree.NdList[0].NdList
+	for i, expect := range []string{"_x_x", "x_x_", "_"} {
+		if got := holes(decls[i].NdFirst); i == 0 && got != expect {
+			t.Errorf("expected pattern elements %s; got %s", expect, got)
+		} else if got := holes(decls[i].NdSecond); i > 0 && got != expect {
+			t.Errorf("expected array elements %s; got %s", expect, got)
+		}
+	}
+	if a := tree.NdList[1].NdFirst; a.NdArity != patternArity || holes(a) != "x_x" {
+		t.Errorf("expected an assignment pattern with a hole; got %v", a)
+	}
+	expectSyntaxError(t, `let o, {a, , b} = o;`, "Bad property name.")
+}
+
+func TestRestAndDefaultParameters(t *testing.T) {
+	defer recoverFromPanic(t)
+	source := `let f = function (a, b = a + 1, ...c) { return c; }, g = (x = 1, ...[y]) => y;`
+	tree := parseString(source)
+	a := tree.NdList[0].NdSecond
+	if len(a.NdList) != 3 {
+		t.Fatalf("expected three parameters; got %v", a)
+	}
+	if b := a.NdList[1]; b.NdId != "=" || b.NdFirst.TkValue != "b" || b.NdSecond.TkValue != "+" {
+		t.Errorf("expected `b = a + 1`; got %v", b)
+	}
+	if b := a.NdList[2]; b.NdId != "..." || b.NdFirst.TkValue != "c" {
+		t.Errorf("expected `...c`; got %v", b)
+	}
+	a = tree.NdList[1].NdSecond
+	if len(a.NdList) != 2 || a.NdList[1].NdId != "..." ||
+		a.NdList[1].NdFirst.NdArity != patternArity {
+		t.Errorf("expected `x = 1, ...[y]`; got %v", a)
+	}
+	expectSyntaxError(t, `let f = function (...a, b) {};`, "Rest parameter must be last.")
+}
+
+func TestSpread(t *testing.T) {
+	defer recoverFromPanic(t)
+	source := `let f, a, o; f(1, ...a); a = [0, ...a]; o = {x: 1, ...o};`
+	tree := parseString(source)
+	a := tree.NdList[0]
+	if a.NdId != "(" || len(a.NdList) != 2 || a.NdList[1].NdId != "..." ||
+		a.NdList[1].NdArity != unaryArity {
+		t.Errorf("expected call with spread argument; got %v", a)
+	}
+	a = tree.NdList[1].NdSecond
+	if len(a.NdList) != 2 || a.NdList[1].NdId != "..." {
+		t.Errorf("expected array literal with spread element; got %v", a)
+	}
+	a = tree.NdList[2].NdSecond
+	if len(a.NdList) != 2 || a.NdList[1].NdId != "..." {
+		t.Errorf("expected object literal with spread property; got %v", a)
+	}
+}
+
+func TestClass(t *testing.T) {
+	defer recoverFromPanic(t)
+	source := `let Base;
+class Point extends Base {
+    constructor(x, y) { super(x); this.y = y; }
+    norm() { return this.y; }
+    static origin() { return 0; }
+    get y() { return 1; }
+    set y(v) { this.v = v; }
+}
+let P = class { static() {} };`
+	tree := parseString(source)
+	a := tree.NdList[0]
+	if a.NdId != "class" || a.NdArity != classArity || a.NdName != "Point" {
+		t.Fatalf("expected class declaration; got %v", a)
+	}
+	if a.NdFirst == nil || a.NdFirst.TkValue != "Base" {
+		t.Errorf("expected `extends Base`; got %v", a.NdFirst)
+	}
+	wanted := []struct {
+		key, kind string
+		static    bool
+	}{
+		{"constructor", "constructor", false},
+		{"norm", "method", false},
+		{"origin", "method", true},
+		{"y", "get", false},
+		{"y", "set", false},
+	}
+	if len(a.NdList) != len(wanted) {
+		t.Fatalf("expected %d members; got %v", len(wanted), a.NdList)
+	}
+	for i, w := range wanted {
+		m := a.NdList[i]
+		if m.NdArity != propertyArity || m.NdFirst.TkValue != w.key ||
+			m.NdKind != w.kind || m.NdStatic != w.static {
+			t.Errorf("member %d: expected %v; got %v", i, w, m)
+		}
+		if m.NdSecond.NdArity != functionArity {
+			t.Errorf("member %d: expected a function; got %v", i, m.NdSecond)
+		}
+	}
+	call := a.NdList[0].NdSecond.NdSecond.NdList[0]
+	if call.NdId != "(" || call.NdFirst.NdArity != superArity {
+		t.Errorf("expected `super(x)`; got %v", call)
+	}
+
+	a = tree.NdList[1].NdSecond
+	if a.NdArity != classArity || a.NdName != "" || len(a.NdList) != 1 ||
+		a.NdList[0].NdFirst.TkValue != "static" || a.NdList[0].NdStatic {
+		t.Errorf("expected class expression with a method named `static`; got %v", a)
+	}
+
+	expectSyntaxError(t, `class A { constructor() {} constructor() {} }`, "Duplicate constructor.")
+}
+
+func TestConst(t *testing.T) {
+	defer recoverFromPanic(t)
+	source := `const a = 1, [b, c] = a;`
+	tree := parseString(source)
+	if tree.NdId != "const" || len(tree.NdList) != 2 {
+		t.Fatalf("expected two const initializations; got %v", tree)
+	}
+	if a := tree.NdList[0].NdFirst; a.NdBinding != "const" {
+		t.Errorf("expected `a` to be const; got %v", a)
+	}
+	if a := tree.NdList[1].NdFirst.NdList[1]; a.NdBinding != "const" {
+		t.Errorf("expected `c` to be const; got %v", a)
+	}
+	expectSyntaxError(t, `const a;`, "Missing initializer in const declaration.")
+	expectSyntaxError(t, `const a = 1; a = 2;`, "Assignment to constant.")
+	expectSyntaxError(t, `const a = 1; a += 2;`, "Assignment to constant.")
+	expectSyntaxError(t, `const a = 1; let b; [a, b] = b;`, "Assignment to constant.")
+	expectSyntaxError(t, `let f = function () { c = 1; }; const c = 2;`, "Assignment to constant.")
+	expectSyntaxError(t, `let f = () => { [c] = [1]; }; const c = 2;`, "Assignment to constant.")
+	expectSyntaxError(t, `let c = 1; { c = 2; const c = 3; }`, "Assignment to constant.")
+	expectSyntaxError(t, `const a = 1; const a = 2;`, "Already defined")
+}
+
+func TestVar(t *testing.T) {
+	defer recoverFromPanic(t)
+	source := `let f = function () {
+    x = 1;
+    if (x) { var x = 2; }
+    var x;
+    return x;
+};`
+	tree := parseString(source)
+	body := tree.NdSecond.NdSecond
+	if body.NdArity != listArity || len(body.NdList) != 3 {
+		t.Fatalf("expected three statements; got %v", body)
+	}
+	if a := body.NdList[1].NdSecond.NdFirst; a.NdBinding != "var" {
+		t.Errorf("expected `x` to be var; got %v", a)
+	}
+	expectSyntaxError(t, `x = 1; { var x; } y = 1;`, "Undefined")
+	expectSyntaxError(t, `let x; { var x; }`, "Already defined")
+	expectSyntaxError(t, `var x; let x;`, "Already defined")
+	expectSyntaxError(t, `let f = function () { x = 1; }; { let x; }`, "Undefined")
+}
+
+func TestForwardReference(t *testing.T) {
+	defer recoverFromPanic(t)
+	source := `let f = function () { return g(); }; let g = () => 42;`
+	tree := parseString(source)
+	if tree.NdArity != listArity || len(tree.NdList) != 2 {
+		t.Errorf("expected two statements; got %v", tree)
+	}
+	expectSyntaxError(t, `let a = b; let b = 1;`, "Undefined")
+}
+
+func TestResolution(t *testing.T) {
+	defer recoverFromPanic(t)
+	source := `let x = 1, o = {};
+let f = function () { x = 2; var x; return y; };
+{ let x = 3; x = 4; }
+x = o.x;
+let y = x;`
+	tree := parseString(source)
+	s := tree.NdList
+	outer, o := s[0].NdList[0].NdFirst, s[0].NdList[1].NdFirst
+	if outer.NdDeclaration != outer {
+		t.Errorf("expected a declaration to link to itself; got %v", outer.NdDeclaration)
+	}
+	body := s[1].NdSecond.NdSecond.NdList
+	if inner := body[0].NdFirst; inner.NdDeclaration == nil || inner.NdDeclaration == outer ||
+		inner.NdDeclaration.NdBinding != "var" {
+		t.Errorf("expected `x` in f to be its hoisted var; got %v", inner.NdDeclaration)
+	}
+	y := s[len(s)-1].NdFirst
+	if r := body[1].NdFirst; r.NdDeclaration != y {
+		t.Errorf("expected `y` in f to be the later let; got %v", r.NdDeclaration)
+	}
+	block := s[2].NdList
+	if b := block[1].NdFirst; b.NdDeclaration != block[0].NdFirst {
+		t.Errorf("expected `x` in the block to be the block's let; got %v", b.NdDeclaration)
+	}
+	a := s[3]
+	if a.NdFirst.NdDeclaration != outer || a.NdSecond.NdFirst.NdDeclaration != o {
+		t.Errorf("expected `x = o.x` to use the outer x and o; got %v", a)
+	}
+	if p := a.NdSecond.NdSecond; p.NdDeclaration != nil {
+		t.Errorf("expected the property name x to have no declaration; got %v", p.NdDeclaration)
+	}
+	if r := s[len(s)-1].NdSecond; r.NdDeclaration != outer {
+		t.Errorf("expected `let y = x` to use the outer x; got %v", r.NdDeclaration)
+	}
+}
+
+func TestUndeclaredNames(t *testing.T) {
+	defer recoverFromPanic(t)
+	parser := NewParser(UndeclaredNames())
+	tree := parser.ParseString(`let f = function () { let c = console; return c; }; x = 1; x = x;`)
+	console := tree.NdList[0].NdSecond.NdSecond.NdList[0].NdSecond
+	if !console.NdUndeclared || console.NdDeclaration != nil {
+		t.Errorf("expected `console` to be undeclared; got %v", console)
+	}
+	for _, s := range tree.NdList[1:] {
+		if x := s.NdFirst; !x.NdUndeclared {
+			t.Errorf("expected `x` to be undeclared; got %v", x)
+		}
+	}
+	expectSyntaxError(t, `x = 1;`, "Undefined")
+}
+
+func TestTemplate(t *testing.T) {
+	defer recoverFromPanic(t)
+	source := "let a, f, s = `x${a + 1}y${ {k: a} }`, u = f`tag${a}`, v = `none`;"
+	tree := parseString(source)
+	a := tree.NdList[0].NdSecond
+	if a.NdArity != templateArity || len(a.NdList) != 5 {
+		t.Fatalf("expected template with five parts; got %v", a)
+	}
+	for i, want := range []string{"x", "+", "y", "{", ""} {
+		if a.NdList[i].TkValue != want {
+			t.Errorf("part %d: expected %q; got %v", i, want, a.NdList[i])
+		}
+	}
+	a = tree.NdList[1].NdSecond
+	if a.NdArity != templateArity || a.NdFirst == nil || a.NdFirst.TkValue != "f" ||
+		len(a.NdList) != 3 {
+		t.Errorf("expected tagged template; got %v", a)
+	}
+	a = tree.NdList[2].NdSecond
+	if a.NdArity != templateArity || len(a.NdList) != 1 || a.NdList[0].TkValue != "none" {
+		t.Errorf("expected template without substitutions; got %v", a)
+	}
+}
+
+func TestRegexp(t *testing.T) {
+	defer recoverFromPanic(t)
+	source := `let a = 4, b = 2, r = /ab+c[/]\//gi, q = a / b / /x/.lastIndex, s = (/(?:y)*/);`
+	tree := NewParser().ParseString(source)
+	r := tree.NdList[2].NdSecond
+	if r.TkType != Regexp || r.NdArity != literalArity || r.TkValue != `/ab+c[/]\//gi` {
+		t.Errorf("expected regular expression; got %v", r)
+	}
+	q := tree.NdList[3].NdSecond
+	if q.TkValue != "/" || q.NdFirst.TkValue != "/" || q.NdSecond.TkValue != "." ||
+		q.NdSecond.NdFirst.TkType != Regexp {
+		t.Errorf("expected `(a / b) / (/x/.lastIndex)`; got %v", q)
+	}
+	if s := tree.NdList[4].NdSecond; s.TkType != Regexp {
+		t.Errorf("expected parenthesized regular expression; got %v", s)
+	}
+}
+
+func TestBadRegexp(t *testing.T) {
+	for _, c := range []struct{ source, message string }{
+		{`let r = /abc;`, "Unterminated regular expression."},
+		{`let r = /abc/gg;`, "Invalid regular expression flags."},
+		{`let r = /abc/x;`, "Invalid regular expression flags."},
+		{`let r = /abc/uv;`, "Invalid regular expression flags."},
+		{`let r = /*a/;`, "nothing to repeat."},
+		{`let r = /a(b/;`, "unterminated group."},
+		{`let r = /a)b/;`, "unmatched ')'."},
+		{`let r = /a[b/;`, "Unterminated regular expression."},
+		{`let r = /a{3,1}/;`, "numbers out of order"},
+		{`let r = /(?x)/;`, "invalid group."},
+	} {
+		func() {
+			defer func() {
+				r := recover()
+				if e, ok := r.(*SyntaxError); !ok || !strings.Contains(e.Message, c.message) {
+					t.Errorf("%s: expected `%s`; got %v", c.source, c.message, r)
+				}
+			}()
+			NewParser().ParseString(c.source)
+		}()
+	}
+}
+
+func TestAutomaticSemicolons(t *testing.T) {
+	defer recoverFromPanic(t)
+	source := `let a = 1, f
+let b = a
+(f)
+a = b
+let g = function () {
+    return
+}
+let h = function () { throw a }`
+	parser := NewParser(AutomaticSemicolons())
+	tree := parser.Parse(TokenizeString(source))
+	if tree.NdArity != listArity || len(tree.NdList) != 5 {
+		t.Fatalf("expected five statements; got %v", tree)
+	}
+	if b := tree.NdList[1].NdSecond; b.NdId != "(" || b.NdFirst.TkValue != "a" {
+		t.Errorf("expected `b = a(f)`; got %v", b)
+	}
+	if r := tree.NdList[3].NdSecond.NdSecond; r.TkValue != "return" || r.NdFirst != nil {
+		t.Errorf("expected `return` without a value; got %v", r)
+	}
+	if r := tree.NdList[4].NdSecond.NdSecond; r.TkValue != "throw" || r.NdFirst.TkValue != "a" {
+		t.Errorf("expected `throw a`; got %v", r)
+	}
+
+	expectSyntaxError(t, "let a = 1\nlet b = 2", "Expected ';'.")
+	func() {
+		defer func() {
+			r := recover()
+			if e, ok := r.(*SyntaxError); !ok || !strings.Contains(e.Message, "Expected ';'.") {
+				t.Errorf("Expected `Expected ';'.`; got %v", r)
+			}
+		}()
+		NewParser(AutomaticSemicolons()).Parse(TokenizeString("let a = 1 let b = 2"))
+	}()
+}
+
+func TestThrowNewline(t *testing.T) {
+	defer func() {
+		r := recover()
+		if e, ok := r.(*SyntaxError); !ok || !strings.Contains(e.Message, "Illegal newline after throw.") {
+			t.Errorf("Expected `Illegal newline after throw.`; got %v", r)
+		}
+	}()
+	NewParser(AutomaticSemicolons()).Parse(TokenizeString("let f = function () { throw\n1; };"))
+}
+
+func TestStringErrors(t *testing.T) {
+	expectSyntaxError(t, `let s = "abc`, "Unterminated string.")
+	expectSyntaxError(t, "let s = `abc", "Unterminated template.")
+	expectSyntaxError(t, `let s = 'a\x';`, "Invalid hexadecimal escape sequence.")
+	expectSyntaxError(t, `let s = @;`, "Unexpected character '@'.")
+}
+
+func TestObjectLiteral(t *testing.T) {
+	defer recoverFromPanic(t)
+	source := `let a, k, o = {
+    a,
+    b: 1,
+    "c": 2,
+    [k]: 3,
+    f(x) { return x; },
+    get g() { return 4; },
+    set g(v) { a = v; },
+    [k + 1]() {},
+    get: 5,
+};`
+	tree := parseString(source)
+	o := tree.NdSecond
+	wanted := []struct {
+		key      string
+		kind     string
+		computed bool
+	}{
+		{"a", "init", false},
+		{"b", "init", false},
+		{`"c"`, "init", false},
+		{"k", "init", true},
+		{"f", "method", false},
+		{"g", "get", false},
+		{"g", "set", false},
+		{"+", "method", true},
+		{"get", "init", false},
+	}
+	if len(o.NdList) != len(wanted) {
+		t.Fatalf("expected %d properties; got %v", len(wanted), o)
+	}
+	for i, w := range wanted {
+		m := o.NdList[i]
+		if m.NdArity != propertyArity || m.NdFirst.TkValue != w.key ||
+			m.NdKind != w.kind || m.NdComputed != w.computed {
+			t.Errorf("property %d: expected %v; got %v", i, w, m)
+		}
+	}
+	if v := o.NdList[0].NdSecond; v.NdArity != nameArity || v.TkValue != "a" {
+		t.Errorf("expected shorthand value `a`; got %v", v)
+	}
+	if v := o.NdList[4].NdSecond; v.NdArity != functionArity || v.NdName != "f" {
+		t.Errorf("expected method `f`; got %v", v)
+	}
+
+	expectSyntaxError(t, `let o = {get g(x) {}};`, "Getter must not have parameters.")
+	expectSyntaxError(t, `let o = {set s() {}};`, "Setter must have exactly one parameter.")
+	expectSyntaxError(t, `let a; ({f() {}} = a);`, "Bad lvalue.")
+}
+
+func TestObjectPatternShorthand(t *testing.T) {
+	defer recoverFromPanic(t)
+	source := `let a, b, o; ({a, b = 2, ["c"]: o} = o);`
+	tree := parseString(source)
+	x := tree.NdFirst
+	if x.NdArity != patternArity || len(x.NdList) != 3 {
+		t.Fatalf("expected object pattern; got %v", x)
+	}
+	if v := x.NdList[1].NdSecond; v.NdId != "=" || v.NdAssignment || v.NdFirst.TkValue != "b" {
+		t.Errorf("expected `b = 2` default; got %v", v)
+	}
+	if m := x.NdList[2]; !m.NdComputed || m.NdSecond.TkValue != "o" {
+		t.Errorf("expected computed key; got %v", m)
+	}
+
+	// An initializer is only allowed once the literal becomes a pattern.
+	tree = parseString(`let a, b, o; [{a = 1}, [{b = 2}]] = o; let f = function () { ({a = 3} = o); };`)
+	if d := tree.NdList[0].NdFirst.NdList[1].NdList[0].NdList[0].NdSecond; d.NdId != "=" || d.NdSecond.TkValue != "2" {
+		t.Errorf("expected `b = 2` default in a nested pattern; got %v", d)
+	}
+	expectSyntaxError(t, `let a; let x = {a = 1};`, "Invalid shorthand property initializer.")
+	expectSyntaxError(t, `let a, f; f({a = 1});`, "Invalid shorthand property initializer.")
+	expectSyntaxError(t, `let a, o; o = [{a = 1}, function () { return 1; }];`, "Invalid shorthand property initializer.")
+}
+
+func TestOptionalChaining(t *testing.T) {
+	defer recoverFromPanic(t)
+	source := `let a, b, c; c = a?.b; c = a?.[b]; c = a?.(b); c = a?.b(c); c = a.b?.(c);`
+	tree := parseString(source)
+	x := tree.NdList[0].NdSecond
+	if x.NdId != "." || !x.NdOptional || x.NdSecond.TkValue != "b" {
+		t.Errorf("expected `a?.b`; got %v", x)
+	}
+	x = tree.NdList[1].NdSecond
+	if x.NdId != "[" || !x.NdOptional || x.NdArity != binaryArity {
+		t.Errorf("expected `a?.[b]`; got %v", x)
+	}
+	x = tree.NdList[2].NdSecond
+	if x.NdId != "(" || !x.NdOptional || x.NdArity != binaryArity || len(x.NdList) != 1 {
+		t.Errorf("expected `a?.(b)`; got %v", x)
+	}
+	x = tree.NdList[3].NdSecond
+	if x.NdId != "(" || !x.NdOptional || x.NdArity != ternaryArity || x.NdFirst.TkValue != "a" || x.NdSecond.TkValue != "b" {
+		t.Errorf("expected optional method call `a?.b(c)`; got %v", x)
+	}
+	x = tree.NdList[4].NdSecond
+	if x.NdId != "(" || !x.NdOptional || x.NdArity != ternaryArity {
+		t.Errorf("expected optional method call; got %v", x)
+	}
+	expectSyntaxError(t, `let a; a?.b = 1;`, "Bad lvalue.")
+	expectSyntaxError(t, `let a; a?.b.c = 1;`, "Bad lvalue.")
+	expectSyntaxError(t, `let a; a?.[0].x = 1;`, "Bad lvalue.")
+	expectSyntaxError(t, `let a; a?.b().c = 1;`, "Bad lvalue.")
+
+	// `?.` followed by a digit is a conditional with a fraction.
+	tree = parseString(`let a, b; b = a?.5:1; b = a ? .5 : 1;`)
+	for _, s := range tree.NdList {
+		if x := s.NdSecond; x.NdId != "?" || x.NdArity != ternaryArity || x.NdSecond.TkValue != ".5" {
+			t.Errorf("expected `a ? .5 : 1`; got %v", x)
+		}
+	}
+}
+
+func TestNullishCoalescing(t *testing.T) {
+	defer recoverFromPanic(t)
+	source := `let a, b, c; c = a ?? b ?? c; c ??= (a || b) ?? c; c = a && (b ?? c);`
+	tree := parseString(source)
+	x := tree.NdList[0].NdSecond
+	if x.NdId != "??" || x.NdFirst.NdId != "??" || x.NdSecond.TkValue != "c" {
+		t.Errorf("expected `(a ?? b) ?? c`; got %v", x)
+	}
+	x = tree.NdList[1]
+	if x.NdId != "??=" || !x.NdAssignment || x.NdSecond.NdFirst.NdId != "||" {
+		t.Errorf("expected `c ??= (a || b) ?? c`; got %v", x)
+	}
+	expectSyntaxError(t, `let a, b, c; c = a ?? b || c;`, "Can't mix '??' with '&&' or '||'")
+	expectSyntaxError(t, `let a, b, c; c = a && b ?? c;`, "Can't mix '??' with '&&' or '||'")
+	expectSyntaxError(t, `let a, b, c; c = a ?? b && c;`, "Can't mix '??' with '&&' or '||'")
+}
+
+func TestGenerator(t *testing.T) {
+	defer recoverFromPanic(t)
+	source := `let g = function* (a) { yield; yield a + 1; yield* a; let x = yield
+a = 1 };`
+	tree := NewParser(AutomaticSemicolons()).ParseString(source)
+	f := tree.NdSecond
+	if f.NdArity != functionArity || !f.NdGenerator || f.NdAsync {
+		t.Fatalf("expected a generator function; got %v", f)
+	}
+	body := f.NdSecond.NdList
+	if body[0].NdId != "yield" || body[0].NdFirst != nil {
+		t.Errorf("expected a bare yield; got %v", body[0])
+	}
+	if body[1].NdId != "yield" || body[1].NdFirst.NdId != "+" {
+		t.Errorf("expected `yield a + 1`; got %v", body[1])
+	}
+	if body[2].NdId != "yield*" || body[2].NdFirst.TkValue != "a" {
+		t.Errorf("expected `yield* a`; got %v", body[2])
+	}
+	if y := body[3].NdSecond; y.NdId != "yield" || y.NdFirst != nil {
+		t.Errorf("expected a bare yield before the line break; got %v", y)
+	}
+	if a := body[4]; a.NdId != "=" || a.NdFirst.TkValue != "a" {
+		t.Errorf("expected the name after the line break to be a statement; got %v", a)
+	}
+
+	// Outside a generator, yield is an ordinary name.
+	tree = parseString(`let yield = 1; let f = function () { yield = 2; };`)
+	if tree.NdList[0].NdFirst.NdBinding != "let" {
+		t.Errorf("expected `yield` to be declared as a variable; got %v", tree.NdList[0])
+	}
+	expectSyntaxError(t, `let a; let f = function () { yield a; };`, "Bad expression statement")
+	expectSyntaxError(t, `let f = function* () { let g = () => { yield 1; }; };`, "Bad expression statement")
+
+	tree = parseString(`let o = {*g() { yield 1; }}; class C { static *h() { yield 2; } }`)
+	if m := tree.NdList[0].NdSecond.NdList[0]; m.NdKind != "method" || !m.NdSecond.NdGenerator {
+		t.Errorf("expected a generator method; got %v", m)
+	}
+	if m := tree.NdList[1].NdList[0]; !m.NdStatic || !m.NdSecond.NdGenerator {
+		t.Errorf("expected a static generator method; got %v", m)
+	}
+}
+
+func TestAsync(t *testing.T) {
+	defer recoverFromPanic(t)
+	source := `let p;
+let f = async function () { await p; return await p.then(); };
+let g = async (a) => await a;
+let h = async a => { await a; };
+let o = {async m() { await p; }, async: 1};
+let async = function (a) { return a; };
+async(1);`
+	tree := parseString(source)
+	f := tree.NdList[0].NdSecond
+	if f.NdArity != functionArity || !f.NdAsync || f.NdGenerator {
+		t.Fatalf("expected an async function; got %v", f)
+	}
+	if a := f.NdSecond.NdList[0]; a.NdId != "await" || a.NdFirst.TkValue != "p" {
+		t.Errorf("expected `await p`; got %v", a)
+	}
+	if a := f.NdSecond.NdList[1].NdFirst; a.NdId != "await" || a.NdFirst.NdId != "(" {
+		t.Errorf("expected `await p.then()`; got %v", a)
+	}
+	g := tree.NdList[1].NdSecond
+	if g.NdId != "=>" || !g.NdAsync || !g.NdConcise || g.NdSecond.NdId != "await" {
+		t.Errorf("expected an async arrow function; got %v", g)
+	}
+	h := tree.NdList[2].NdSecond
+	if h.NdId != "=>" || !h.NdAsync || h.NdList[0].TkValue != "a" {
+		t.Errorf("expected an async arrow function with one parameter; got %v", h)
+	}
+	o := tree.NdList[3].NdSecond
+	if m := o.NdList[0]; m.NdKind != "method" || !m.NdSecond.NdAsync {
+		t.Errorf("expected an async method; got %v", m)
+	}
+	if m := o.NdList[1]; m.NdKind != "init" || m.NdFirst.TkValue != "async" {
+		t.Errorf("expected a property named async; got %v", m)
+	}
+	if c := tree.NdList[5]; c.NdId != "(" || c.NdFirst.TkValue != "async" {
+		t.Errorf("expected a call of async; got %v", c)
+	}
+
+	// Outside an async function, await is an ordinary name.
+	parseString(`let await = 1; await = 2;`)
+	expectSyntaxError(t, `let p; let f = function () { await p; };`, "Bad expression statement")
+	expectSyntaxError(t, `let p; let f = async () => () => await p;`, "Expected ';'.")
+	expectSyntaxError(t, `class C { async constructor() {} }`, "Constructor can't be async or a generator.")
+}
+
+func TestImport(t *testing.T) {
+	defer recoverFromPanic(t)
+	source := `import a from "./a.js";
+import b, {c, d as e, "f g" as h} from './b.js';
+import * as ns from "./ns.js";
+import "./polyfill.js";
+let x = a + b + c + e + h + ns.y;`
+	parser := NewParser()
+	tree := parser.ParseString(source)
+	i := tree.NdList[1]
+	if i.NdId != "import" || len(i.NdList) != 4 || i.NdSecond.TkValue != `'./b.js'` {
+		t.Fatalf("expected an import of four bindings; got %v", i)
+	}
+	if m := i.NdList[2]; m.NdFirst.TkValue != "d" || m.NdSecond.TkValue != "e" ||
+		m.NdSecond.NdBinding != "import" {
+		t.Errorf("expected `d as e`; got %v", m)
+	}
+	expected := []Import{
+		{Source: "./a.js", Name: "default", Local: "a"},
+		{Source: "./b.js", Name: "default", Local: "b"},
+		{Source: "./b.js", Name: "c", Local: "c"},
+		{Source: "./b.js", Name: "d", Local: "e"},
+		{Source: "./b.js", Name: "f g", Local: "h"},
+		{Source: "./ns.js", Name: "*", Local: "ns"},
+		{Source: "./polyfill.js"},
+	}
+	if !reflect.DeepEqual(parser.Module().Imports, expected) {
+		t.Errorf("expected imports %v; got %v", expected, parser.Module().Imports)
+	}
+	expectSyntaxError(t, `import a from "m"; a = 1;`, "Assignment to an imported binding.")
+	expectSyntaxError(t, `import a from "m"; let a;`, "Already defined")
+	expectSyntaxError(t, `import a "m";`, "Expected 'from'.")
+	expectSyntaxError(t, `import {a} from m;`, "Expected a module specifier.")
+	expectSyntaxError(t, `{ import a from "m"; }`, "'import' must be at the top level.")
+}
+
+func TestExport(t *testing.T) {
+	defer recoverFromPanic(t)
+	source := `export let a = 1, [b, c] = [2, 3];
+export const d = 4;
+export class E {}
+export {f, a as g};
+export default function () { return f; };
+var f;`
+	parser := NewParser()
+	tree := parser.ParseString(source)
+	if x := tree.NdList[0]; x.NdId != "export" || x.NdFirst.NdId != "let" {
+		t.Errorf("expected an exported let; got %v", x)
+	}
+	if x := tree.NdList[3]; len(x.NdList) != 2 || x.NdList[1].NdFirst.TkValue != "g" ||
+		x.NdList[1].NdSecond.TkValue != "a" {
+		t.Errorf("expected `export {f, a as g}`; got %v", x)
+	}
+	expected := []Export{
+		{Name: "a", Local: "a"},
+		{Name: "b", Local: "b"},
+		{Name: "c", Local: "c"},
+		{Name: "d", Local: "d"},
+		{Name: "E", Local: "E"},
+		{Name: "f", Local: "f"},
+		{Name: "g", Local: "a"},
+		{Name: "default"},
+	}
+	if !reflect.DeepEqual(parser.Module().Exports, expected) {
+		t.Errorf("expected exports %v; got %v", expected, parser.Module().Exports)
+	}
+	expectSyntaxError(t, `let a; export {a}; export {a};`, "Duplicate export of 'a'.")
+	expectSyntaxError(t, `export {a};`, "Undefined")
+	expectSyntaxError(t, `export 1;`, "Expected a declaration, 'default' or '{' after 'export'.")
+	expectSyntaxError(t, `let f = function () { export let a = 1; };`, "'export' must be at the top level.")
+}
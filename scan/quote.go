@@ -0,0 +1,121 @@
+package scan
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Unquote interprets s, a string literal quoted with " or ', returning the
+// string value that s quotes.
+func Unquote(s string) (string, error) {
+	if len(s) < 2 || (s[0] != '"' && s[0] != '\'') || s[len(s)-1] != s[0] {
+		return "", errors.New("Not a string literal.")
+	}
+	s = s[1 : len(s)-1]
+	var value strings.Builder
+	for len(s) > 0 {
+		if s[0] != '\\' {
+			value.WriteByte(s[0])
+			s = s[1:]
+			continue
+		}
+		v, n, err := escape(s)
+		if err != nil {
+			return "", err
+		}
+		value.WriteString(v)
+		s = s[n:]
+	}
+	return value.String(), nil
+}
+
+// escape decodes the escape sequence at the start of s, which begins with a
+// backslash. It returns the text the sequence stands for and the length of
+// the sequence, or an error describing why the sequence is malformed.
+func escape(s string) (string, int, error) {
+	if len(s) < 2 {
+		return "", len(s), errors.New("Unterminated escape sequence.")
+	}
+	switch c := s[1]; c {
+	case 'b':
+		return "\b", 2, nil
+	case 'f':
+		return "\f", 2, nil
+	case 'n':
+		return "\n", 2, nil
+	case 'r':
+		return "\r", 2, nil
+	case 't':
+		return "\t", 2, nil
+	case 'v':
+		return "\v", 2, nil
+	case '\n':
+		return "", 2, nil // a line continuation
+	case '0':
+		if len(s) > 2 && '0' <= s[2] && s[2] <= '9' {
+			return "", 3, errors.New("Octal escape sequences are not allowed.")
+		}
+		return "\x00", 2, nil
+	case '1', '2', '3', '4', '5', '6', '7':
+		return "", 2, errors.New("Octal escape sequences are not allowed.")
+	case '8', '9':
+		return "", 2, errors.New("\\8 and \\9 are not allowed.")
+	case 'x':
+		if r, ok := hex(s[2:], 2); ok {
+			return string(rune(r)), 4, nil
+		}
+		return "", 2, errors.New("Invalid hexadecimal escape sequence.")
+	case 'u':
+		if strings.HasPrefix(s, `\u{`) {
+			end := strings.IndexByte(s, '}')
+			if end < 0 {
+				return "", 2, errors.New("Invalid Unicode escape sequence.")
+			}
+			r, ok := hex(s[3:end], end-3)
+			if !ok || end == 3 {
+				return "", 2, errors.New("Invalid Unicode escape sequence.")
+			}
+			if r > utf8.MaxRune {
+				return "", end + 1, errors.New("Undefined Unicode code-point.")
+			}
+			return string(rune(r)), end + 1, nil
+		}
+		r, ok := hex(s[2:], 4)
+		if !ok {
+			return "", 2, errors.New("Invalid Unicode escape sequence.")
+		}
+		if 0xD800 <= r && r < 0xDC00 && strings.HasPrefix(s[6:], `\u`) {
+			// A surrogate pair.
+			if low, ok := hex(s[8:], 4); ok && 0xDC00 <= low && low < 0xE000 {
+				return string(rune(0x10000 + (r-0xD800)<<10 + (low - 0xDC00))), 12, nil
+			}
+		}
+		return string(rune(r)), 6, nil
+	default:
+		_, n := utf8.DecodeRuneInString(s[1:])
+		return s[1 : 1+n], 1 + n, nil
+	}
+}
+
+// hex decodes the first n characters of s as a hexadecimal number. It reports
+// false if s has fewer than n characters or any of them isn't a hex digit.
+// Leading zeros don't matter; a value too large for any code point is
+// returned as some value above utf8.MaxRune.
+func hex(s string, n int) (int64, bool) {
+	if len(s) < n {
+		return 0, false
+	}
+	var r int64
+	for i := 0; i < n; i++ {
+		d := strings.IndexRune("0123456789abcdef", unicode.ToLower(rune(s[i])))
+		if d < 0 {
+			return 0, false
+		}
+		if r <= utf8.MaxRune {
+			r = r*16 + int64(d)
+		}
+	}
+	return r, true
+}
@@ -0,0 +1,176 @@
+package scan
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// checkRegexp checks the syntax of a regular expression literal, /body/flags,
+// as produced by the lexer.
+func checkRegexp(literal string) error {
+	end := strings.LastIndexByte(literal, '/')
+	if end < 1 || !closesRegexp(literal, end) {
+		return errors.New("Unterminated regular expression.")
+	}
+	if err := checkRegexpFlags(literal[end+1:]); err != nil {
+		return err
+	}
+	return checkRegexpBody(literal[1:end])
+}
+
+// closesRegexp reports whether the '/' at index end of literal is the closing
+// delimiter rather than an escaped or bracketed one.
+func closesRegexp(literal string, end int) bool {
+	class := false
+	for i := 1; i < end; i++ {
+		switch literal[i] {
+		case '\\':
+			i += 1
+		case '[':
+			class = true
+		case ']':
+			class = false
+		case '/':
+			if !class {
+				return false
+			}
+		}
+	}
+	return !class
+}
+
+func checkRegexpFlags(flags string) error {
+	for i, f := range flags {
+		if !strings.ContainsRune("dgimsuvy", f) || strings.ContainsRune(flags[:i], f) {
+			return errors.New("Invalid regular expression flags.")
+		}
+	}
+	if strings.ContainsRune(flags, 'u') && strings.ContainsRune(flags, 'v') {
+		return errors.New("Invalid regular expression flags.")
+	}
+	return nil
+}
+
+func checkRegexpBody(body string) error {
+	groups := 0
+	atom := false       // the previous element can be quantified
+	quantified := false // the previous element is a quantifier
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch c {
+		case '\\':
+			if i+1 >= len(body) {
+				return errors.New("Invalid regular expression: \\ at end of pattern.")
+			}
+			i += 1
+			atom, quantified = true, false
+		case '[':
+			j := i + 1
+			for ; j < len(body) && body[j] != ']'; j++ {
+				if body[j] == '\\' {
+					j += 1
+				}
+			}
+			if j >= len(body) {
+				return errors.New("Invalid regular expression: unterminated character class.")
+			}
+			i = j
+			atom, quantified = true, false
+		case '(':
+			if strings.HasPrefix(body[i:], "(?") {
+				n := groupPrefix(body[i:])
+				if n == 0 {
+					return errors.New("Invalid regular expression: invalid group.")
+				}
+				i += n - 1
+			}
+			groups += 1
+			atom, quantified = false, false
+		case ')':
+			if groups == 0 {
+				return errors.New("Invalid regular expression: unmatched ')'.")
+			}
+			groups -= 1
+			atom, quantified = true, false
+		case '*', '+', '?':
+			if c == '?' && quantified {
+				quantified = false // a lazy quantifier
+				continue
+			}
+			if !atom {
+				return errors.New("Invalid regular expression: nothing to repeat.")
+			}
+			atom, quantified = false, true
+		case '{':
+			n, min, max := braceQuantifier(body[i:])
+			if n == 0 {
+				atom, quantified = true, false // a literal '{'
+				continue
+			}
+			if !atom {
+				return errors.New("Invalid regular expression: nothing to repeat.")
+			}
+			if max >= 0 && max < min {
+				return errors.New("Invalid regular expression: numbers out of order in {} quantifier.")
+			}
+			i += n - 1
+			atom, quantified = false, true
+		case '|', '^', '$':
+			atom, quantified = false, false
+		default:
+			atom, quantified = true, false
+		}
+	}
+	if groups > 0 {
+		return errors.New("Invalid regular expression: unterminated group.")
+	}
+	return nil
+}
+
+// groupPrefix returns the length of the prefix of a group that begins with
+// "(?" -- such as "(?:", "(?=" or "(?<name>" -- or 0 if the prefix is invalid.
+func groupPrefix(s string) int {
+	for _, prefix := range []string{"(?:", "(?=", "(?!", "(?<=", "(?<!"} {
+		if strings.HasPrefix(s, prefix) {
+			return len(prefix)
+		}
+	}
+	if strings.HasPrefix(s, "(?<") {
+		if end := strings.IndexByte(s, '>'); end > 3 {
+			for i := 3; i < end; i++ {
+				if !isNameByte(s[i]) {
+					return 0
+				}
+			}
+			return end + 1
+		}
+	}
+	return 0
+}
+
+// braceQuantifier parses a quantifier of the form {n}, {n,} or {n,m} at the
+// start of s. It returns its length (0 if s doesn't start with one) and its
+// bounds; max is -1 if there is no upper bound.
+func braceQuantifier(s string) (n, min, max int) {
+	end := strings.IndexByte(s, '}')
+	if end < 0 {
+		return 0, 0, 0
+	}
+	bounds := strings.SplitN(s[1:end], ",", 2)
+	min, err := strconv.Atoi(bounds[0])
+	if err != nil || bounds[0][0] == '+' || bounds[0][0] == '-' {
+		return 0, 0, 0
+	}
+	max = min
+	if len(bounds) == 2 {
+		max = -1
+		if bounds[1] != "" {
+			if max, err = strconv.Atoi(bounds[1]); err != nil ||
+				bounds[1][0] == '+' || bounds[1][0] == '-' {
+				return 0, 0, 0
+			}
+		}
+	}
+	return end + 1, min, max
+}
@@ -21,11 +21,15 @@ const (
 	Error        // error occurred; value is text of error
 
 	Name       // alphanumeric identifier
-	Punctuator // ( ) { } [ ] ? . , : ; ~ * /
+	Punctuator // ( ) { } [ ] ? . , : ; ~ * / ...
 	Fixnum
 	Flonum
 	String
 	UnterminatedString
+	Template     // a template literal, or the last piece of one: `...` or }...`
+	TemplateHead // a piece of a template literal before a substitution: `...${ or }...${
+	Regexp       // a regular expression literal: /body/flags
+	Custom       // a token of a class added with the TokenClass option
 
 	Literal
 	//)
@@ -43,6 +47,12 @@ const (
 	ternaryArity
 	statementArity
 	listArity
+	patternArity
+	classArity
+	superArity
+	propertyArity
+	templateArity
+	holeArity // an elided element of an array literal or pattern: [a, , b]
 )
 
 const reWhitespace = `(\s+)`
@@ -52,12 +62,19 @@ const reFixnum = `(\d+)`
 const reFloat1 = `\d+[eE][+\-]?\d+`
 const reFloat2 = `\d+\.\d+[eE][+\-]?\d+`
 const reFloat3 = `\d+\.\d+`
-const reFlonum = `(` + reFloat1 + `|` + reFloat2 + `|` + reFloat3 + `)`
-const reString = `("(?:[^"\\]|\\(?:.|u[0-9a-fA-F]{4}))*")`
-const rePunctuator = `([(){}\[\]?.,:;~*\/]|&&?|\|\|?|[+\-<>]=?|[!=](?:==)?)`
-const reUnterminatedString = `("(?:[^"\\]|\\(?:.|u[0-9a-fA-F]{4}))*)`
+const reFloat4 = `\.\d+(?:[eE][+\-]?\d+)?`
+const reFlonum = `(` + reFloat1 + `|` + reFloat2 + `|` + reFloat3 + `|` + reFloat4 + `)`
 const reError = `(.)`
 
+// defaultPunctuators are the punctuators that TokenizeString and TokenizeLines
+// recognize. A Parser recognizes those of its symbols that are spelled with
+// punctuation characters instead.
+var defaultPunctuators = newTrie([]string{
+	"(", ")", "{", "}", "[", "]", "?", ".", ",", ":", ";", "~", "*", "/",
+	"...", "??", "??=", "?.", "&", "&&", "|", "||", "+", "+=", "-", "-=",
+	"<", "<=", ">", ">=", "=>", "!", "!==", "=", "===",
+})
+
 var tokenRegex = regexp.MustCompile(
 	strings.Join([]string{
 		reWhitespace,
@@ -65,16 +82,13 @@ var tokenRegex = regexp.MustCompile(
 		reName,
 		reFlonum,
 		reFixnum,
-		reString,
-		rePunctuator,
-		reUnterminatedString,
 		reError,
 	},
 		"|"),
 )
 
-type UnaryDenotation func(this *Token) *Token
-type BinaryDenotation func(this, left *Token) *Token
+type UnaryDenotation func(p *Parser, this *Token) *Token
+type BinaryDenotation func(p *Parser, this, left *Token) *Token
 
 // Token represents a lexical unit returned from the scanner.
 type Token struct {
@@ -82,6 +96,7 @@ type Token struct {
 	TkValue    string // The text of this item.
 	TkLine     int    // The line number on which this token appears
 	TkColumn   int    // The column number at which this token appears
+	TkNewline  bool   // A line break precedes this token
 	TkReserved bool
 
 	TkNud UnaryDenotation
@@ -92,17 +107,50 @@ type Token struct {
 	NdId    string
 	NdArity Type /*Arity*/
 
-	NdAssignment bool
-	NdFirst      *Token
-	NdSecond     *Token
-	NdThird      *Token
-	NdList       []*Token
-	NdName       string
-	NdKey        string
+	NdAssignment    bool
+	NdFirst         *Token
+	NdSecond        *Token
+	NdThird         *Token
+	NdList          []*Token
+	NdName          string
+	NdConcise       bool   // an arrow function whose body is an expression
+	NdKind          string // a property's kind: method, get, set or constructor
+	NdStatic        bool   // a static class member
+	NdComputed      bool   // a property whose key is computed: [key]
+	NdOptional      bool   // an optional member access or call: a?.b, a?.[b], a?.(b)
+	NdBinding       string // a defined name's declaration kind: let, const, var, ...
+	NdParenthesized bool   // an expression enclosed in parentheses
+	NdGenerator     bool   // a generator function: function* f() {}
+	NdAsync         bool   // an async function or arrow function
+
+	// NdDeclaration links a name node to the name node that declares it; a
+	// declaration links to itself. A name that is never declared has none,
+	// and is NdUndeclared: a global, if the parser accepts those.
+	NdDeclaration *Token
+	NdUndeclared  bool
+
+	// Set by CaptureAnalysis.
+	NdCaptures   []*Token // a function's free variables
+	NdLocals     []*Token // a function's own declarations
+	NdCaptured   bool     // a declaration used by a nested function
+	NdReassigned bool     // a captured declaration assigned after capture
+}
+
+// A SyntaxError is what Token.Error panics with: a message about the token
+// at Line and Column.
+type SyntaxError struct {
+	Message string
+	Line    int
+	Column  int
+	Token   *Token
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("SyntaxError;  %s while processing %v", e.Message, e.Token)
 }
 
 func (t *Token) Error(message string) {
-	panic(fmt.Sprintf("SyntaxError;  %s while processing %v", message, t))
+	panic(&SyntaxError{Message: message, Line: t.TkLine, Column: t.TkColumn, Token: t})
 }
 
 func (t *Token) PrettyPrint(b io.Writer, indent string) {
@@ -120,8 +168,32 @@ func (t *Token) PrettyPrint(b io.Writer, indent string) {
 	if t.NdName != "" {
 		fmt.Fprintf(b, " name:%q", t.NdName)
 	}
-	if t.NdKey != "" {
-		fmt.Fprintf(b, " key:%q", t.NdKey)
+	if t.NdConcise {
+		fmt.Fprintf(b, " concise")
+	}
+	if t.NdKind != "" {
+		fmt.Fprintf(b, " %s", t.NdKind)
+	}
+	if t.NdStatic {
+		fmt.Fprintf(b, " static")
+	}
+	if t.NdComputed {
+		fmt.Fprintf(b, " computed")
+	}
+	if t.NdOptional {
+		fmt.Fprintf(b, " optional")
+	}
+	if t.NdGenerator {
+		fmt.Fprintf(b, " generator")
+	}
+	if t.NdAsync {
+		fmt.Fprintf(b, " async")
+	}
+	if t.NdBinding != "" {
+		fmt.Fprintf(b, " %s", t.NdBinding)
+	}
+	if t.NdUndeclared {
+		fmt.Fprintf(b, " undeclared")
 	}
 	//	if t.NdList == nil || len(t.NdList) == 0 {
 	//		fmt.Fprintf(b, " NdList:empty")
@@ -146,6 +218,15 @@ func (t *Token) PrettyPrint(b io.Writer, indent string) {
 	}
 }
 
+// literal returns a copy of the name or literal t, for use as a literal such
+// as a property key.
+func (t *Token) literal() *Token {
+	l := &Token{}
+	*l = *t
+	l.NdArity = literalArity
+	return l
+}
+
 func (t *Token) String() string {
 	var s strings.Builder
 	t.PrettyPrint(&s, "")
@@ -155,55 +236,293 @@ func (t *Token) String() string {
 // TokenizeString analyzes the source string and returns it as an array of
 // Tokens.
 func TokenizeString(source string) []*Token {
-	return TokenizeLines(
-		strings.Split(strings.Replace(source, "\r\n", "\n", -1), "\n"),
-	)
+	return TokenizeLines(splitLines(source))
+}
+
+func splitLines(source string) []string {
+	return strings.Split(strings.Replace(source, "\r\n", "\n", -1), "\n")
 }
 
 // TokenizeLines analyzes the array of source strings and returns it as an array
 // of Tokens.
+//
+// Whether a '/' begins a regular expression or is a division operator depends
+// on whether the parser expects an operand, so without a parser to ask, a '/'
+// is always an operator. Parser.ParseString lexes regular expressions.
 func TokenizeLines(sourceLines []string) []*Token {
-	var lineNumber int = 0
-	var linevalue string = ""
-	var loc []int
+	l := &lexer{lines: sourceLines, punctuators: defaultPunctuators}
 	var result = []*Token{}
+	for t := l.next(false); t != nil; t = l.next(false) {
+		result = append(result, t)
+	}
+	return result
+}
+
+// A lexer produces tokens one at a time from an array of source lines.
+type lexer struct {
+	lines  []string
+	line   int // index of the current line
+	column int // byte offset of the next character within the current line
+
+	// templates has an entry for each template substitution (`${...}`) being
+	// lexed, counting the '{' opened within it and not yet closed. The '}'
+	// that closes the substitution resumes the template.
+	templates []int
+
+	// marks holds the state of the lexer before each token it has returned,
+	// so that it can rewind and lex a token again.
+	marks []lexerState
+
+	punctuators *trie        // the punctuators to recognize, longest first
+	classes     []tokenClass // custom token classes, tried before the others
+}
+
+// A tokenClass is a kind of token added to the lexer by the TokenClass
+// option. Its tokens are looked up in the symbol table as symbol.
+type tokenClass struct {
+	symbol  string
+	pattern *regexp.Regexp
+}
 
-	emit := func(t Type, locIndex int) {
-		first := loc[locIndex]
-		after := loc[locIndex+1]
-		result = append(result, &Token{
-			TkType:   t,
-			TkValue:  linevalue[first:after],
-			TkLine:   lineNumber + 1,
-			TkColumn: first,
-		})
-	}
-	for lineNumber, linevalue = range sourceLines {
-		allIndexes := tokenRegex.FindAllStringSubmatchIndex(linevalue, -1)
-		for _, loc = range allIndexes {
-			if loc[2] >= 0 {
-				// skip whitespace
-			} else if loc[4] >= 0 {
-				// skip comment
-			} else if loc[6] >= 0 {
-				emit(Name, 6)
-			} else if loc[8] >= 0 {
-				emit(Flonum, 8)
-			} else if loc[10] >= 0 {
-				emit(Fixnum, 10)
-			} else if loc[12] >= 0 {
-				emit(String, 12)
-			} else if loc[14] >= 0 {
-				emit(Punctuator, 14)
-			} else if loc[16] >= 0 {
-				emit(UnterminatedString, 16)
-			} else if loc[18] >= 0 {
-				emit(Error, 18)
-			} else {
-				panic(`token regex didn't match *anything*`)
+type lexerState struct {
+	line, column int
+	templates    []int
+}
+
+// rewind restores the lexer to its state before it returned its n'th token
+// (counting from zero).
+func (l *lexer) rewind(n int) {
+	m := l.marks[n]
+	l.line, l.column = m.line, m.column
+	l.templates = append([]int(nil), m.templates...)
+	l.marks = l.marks[:n]
+}
+
+// next returns the next token, or nil at the end of the input. If operand is
+// true, a '/' begins a regular expression literal rather than an operator.
+func (l *lexer) next(operand bool) *Token {
+	l.marks = append(l.marks, lexerState{
+		line:      l.line,
+		column:    l.column,
+		templates: append([]int(nil), l.templates...),
+	})
+	t := l.scan(operand)
+	if t == nil {
+		l.marks = l.marks[:len(l.marks)-1]
+	} else if m := l.marks[len(l.marks)-1]; t.TkLine-1 > m.line && len(l.marks) > 1 {
+		t.TkNewline = true
+	}
+	return t
+}
+
+func (l *lexer) scan(operand bool) *Token {
+	for l.line < len(l.lines) {
+		text := l.lines[l.line]
+		if l.column >= len(text) {
+			l.line += 1
+			l.column = 0
+			continue
+		}
+		top := len(l.templates) - 1
+		if text[l.column] == '`' {
+			return l.template()
+		} else if text[l.column] == '"' || text[l.column] == '\'' {
+			return l.string()
+		} else if text[l.column] == '}' && top >= 0 && l.templates[top] == 0 {
+			l.templates = l.templates[:top]
+			return l.template()
+		} else if operand && strings.HasPrefix(text[l.column:], "/") &&
+			!strings.HasPrefix(text[l.column:], "//") {
+			return l.regexp()
+		}
+
+		for _, c := range l.classes {
+			if loc := c.pattern.FindStringIndex(text[l.column:]); loc != nil && loc[1] > 0 {
+				t := l.token(Custom, text[l.column:l.column+loc[1]], l.column)
+				t.NdId = c.symbol
+				l.column += loc[1]
+				return t
 			}
 		}
+
+		loc := tokenRegex.FindStringSubmatchIndex(text[l.column:])
+		first := l.column
+		l.column += loc[1]
+		emit := func(t Type, locIndex int) *Token {
+			return l.token(t, text[first+loc[locIndex]:first+loc[locIndex+1]], first)
+		}
+		if loc[2] >= 0 {
+			// skip whitespace
+		} else if loc[4] >= 0 {
+			// skip comment
+		} else if loc[6] >= 0 {
+			return emit(Name, 6)
+		} else if loc[8] >= 0 {
+			return emit(Flonum, 8)
+		} else if loc[10] >= 0 {
+			return emit(Fixnum, 10)
+		} else if n := l.punctuators.longest(text[first:]); n > 0 {
+			l.column = first + n
+			return l.punctuator(text, first, n, top)
+		} else if loc[12] >= 0 {
+			t := emit(Error, 12)
+			t.TkValue = fmt.Sprintf("Unexpected character '%s'.", t.TkValue)
+			return t
+		} else {
+			panic(`token regex didn't match *anything*`)
+		}
 	}
-	loc = []int{len(linevalue), len(linevalue)}
-	return result
+	return nil
+}
+
+// punctuator returns the punctuator of length n at text[first:], which the
+// lexer has moved past.
+func (l *lexer) punctuator(text string, first, n, top int) *Token {
+	t := l.token(Punctuator, text[first:first+n], first)
+	if t.TkValue == "?." && l.column < len(text) &&
+		'0' <= text[l.column] && text[l.column] <= '9' {
+		// `a?.5:b` is a conditional, not an optional chain.
+		t.TkValue = "?"
+		l.column -= 1
+	}
+	if top >= 0 && t.TkValue == "{" {
+		l.templates[top] += 1
+	} else if top >= 0 && t.TkValue == "}" {
+		l.templates[top] -= 1
+	}
+	return t
+}
+
+func (l *lexer) token(t Type, value string, column int) *Token {
+	return &Token{
+		TkType:   t,
+		TkValue:  value,
+		TkLine:   l.line + 1,
+		TkColumn: column,
+	}
+}
+
+// string lexes a string literal quoted with " or '. A backslash at the end of
+// a line continues the string on the next line. A malformed escape sequence
+// produces an Error token positioned at the escape; a string that isn't closed
+// before the end of its line produces an UnterminatedString token.
+func (l *lexer) string() *Token {
+	line, column := l.line, l.column
+	quote := l.lines[l.line][l.column]
+	var value strings.Builder
+	var bad *Token
+	value.WriteByte(quote)
+	l.column += 1
+	for {
+		text := l.lines[l.line]
+		if l.column >= len(text) {
+			t := l.token(UnterminatedString, value.String(), column)
+			t.TkLine = line + 1
+			return t
+		}
+		c := text[l.column]
+		if c == '\\' && l.column+1 == len(text) && l.line+1 < len(l.lines) {
+			value.WriteString("\\\n")
+			l.line += 1
+			l.column = 0
+			continue
+		}
+		n := 1
+		if c == '\\' {
+			var err error
+			if _, n, err = escape(text[l.column:]); err != nil && bad == nil {
+				bad = l.token(Error, err.Error(), l.column)
+			}
+		}
+		value.WriteString(text[l.column : l.column+n])
+		l.column += n
+		if c == quote {
+			break
+		}
+	}
+	if bad != nil {
+		return bad
+	}
+	t := l.token(String, value.String(), column)
+	t.TkLine = line + 1
+	return t
+}
+
+// regexp lexes a regular expression literal, /body/flags, which must fit on
+// the current line. An unterminated literal extends to the end of the line;
+// the parser checks its syntax.
+func (l *lexer) regexp() *Token {
+	text := l.lines[l.line]
+	first := l.column
+	i := first + 1
+	class := false
+	for ; i < len(text); i++ {
+		c := text[i]
+		if c == '\\' {
+			i += 1
+		} else if c == '[' {
+			class = true
+		} else if c == ']' {
+			class = false
+		} else if c == '/' && !class {
+			i += 1
+			for i < len(text) && isNameByte(text[i]) {
+				i += 1
+			}
+			break
+		}
+	}
+	if i > len(text) {
+		i = len(text)
+	}
+	l.column = i
+	return l.token(Regexp, text[first:i], first)
+}
+
+func isNameByte(c byte) bool {
+	return c == '_' || c == '$' || '0' <= c && c <= '9' ||
+		'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// template lexes a piece of a template literal. A piece starts at the opening
+// '`' or at the '}' that closes a substitution, and ends at the closing '`'
+// (a Template token) or at the '${' that opens the next substitution (a
+// TemplateHead token). A template may span several lines.
+func (l *lexer) template() *Token {
+	line, column := l.line, l.column
+	var value strings.Builder
+	value.WriteByte(l.lines[l.line][l.column])
+	l.column += 1
+	emit := func(t Type) *Token {
+		token := l.token(t, value.String(), column)
+		token.TkLine = line + 1
+		return token
+	}
+	for l.line < len(l.lines) {
+		text := l.lines[l.line]
+		for l.column < len(text) {
+			c := text[l.column]
+			if c == '\\' && l.column+1 < len(text) {
+				value.WriteString(text[l.column : l.column+2])
+				l.column += 2
+				continue
+			}
+			value.WriteByte(c)
+			l.column += 1
+			if c == '`' {
+				return emit(Template)
+			} else if c == '$' && l.column < len(text) && text[l.column] == '{' {
+				value.WriteByte('{')
+				l.column += 1
+				l.templates = append(l.templates, 0)
+				return emit(TemplateHead)
+			}
+		}
+		l.line += 1
+		l.column = 0
+		if l.line < len(l.lines) {
+			value.WriteByte('\n')
+		}
+	}
+	return emit(UnterminatedString)
 }
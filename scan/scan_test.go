@@ -26,8 +26,7 @@ var testcases []testcase = []testcase{
 			{Punctuator, ")"},
 			{Punctuator, "{"},
 			{Punctuator, "}"},
-			{Punctuator, "?"},
-			{Punctuator, "."},
+			{Punctuator, "?."},
 			{Punctuator, ","},
 			{Punctuator, ":"},
 			{Punctuator, ";"},
@@ -57,7 +56,38 @@ var testcases []testcase = []testcase{
 		},
 	},
 	{
-		input: "000 1 42\n 3.1415926 1.2\n 3. .4 5.6e7",
+		input: "....a...",
+		output: []wanted{
+			{Punctuator, "..."},
+			{Punctuator, "."},
+			{Name, "a"},
+			{Punctuator, "..."},
+		},
+	},
+	{
+		input: "a?.b??c??=d?.5",
+		output: []wanted{
+			{Name, "a"},
+			{Punctuator, "?."},
+			{Name, "b"},
+			{Punctuator, "??"},
+			{Name, "c"},
+			{Punctuator, "??="},
+			{Name, "d"},
+			{Punctuator, "?"},
+			{Flonum, ".5"},
+		},
+	},
+	{
+		input: "=>===>=",
+		output: []wanted{
+			{Punctuator, "=>"},
+			{Punctuator, "==="},
+			{Punctuator, ">="},
+		},
+	},
+	{
+		input: "000 1 42\n 3.1415926 1.2\n 3. .4 5.6e7 .5e-3",
 		output: []wanted{
 			{Fixnum, "000"},
 			{Fixnum, "1"},
@@ -66,9 +96,9 @@ var testcases []testcase = []testcase{
 			{Flonum, "1.2"},
 			{Fixnum, "3"},
 			{Punctuator, "."},
-			{Punctuator, "."},
-			{Fixnum, "4"},
+			{Flonum, ".4"},
 			{Flonum, "5.6e7"},
+			{Flonum, ".5e-3"},
 		},
 	},
 	{
@@ -82,6 +112,46 @@ var testcases []testcase = []testcase{
 			{UnterminatedString, `"unfinished business`},
 		},
 	},
+	{
+		input: "`plain` `a${b}c${ {d: `e${f}`} }g\nh` `\\`${`",
+		output: []wanted{
+			{Template, "`plain`"},
+			{TemplateHead, "`a${"},
+			{Name, "b"},
+			{TemplateHead, "}c${"},
+			{Punctuator, "{"},
+			{Name, "d"},
+			{Punctuator, ":"},
+			{TemplateHead, "`e${"},
+			{Name, "f"},
+			{Template, "}`"},
+			{Punctuator, "}"},
+			{Template, "}g\nh`"},
+			{TemplateHead, "`\\`${"},
+			{UnterminatedString, "`"},
+		},
+	},
+	{
+		input: `'a"b' '\'' "\x41\u0042\u{1F600}\0" 'one\
+two' "\xG1" '\u{110000}' "\07" "ok" 'oops`,
+		output: []wanted{
+			{String, `'a"b'`},
+			{String, `'\''`},
+			{String, `"\x41\u0042\u{1F600}\0"`},
+			{String, "'one\\\ntwo'"},
+			{Error, "Invalid hexadecimal escape sequence."},
+			{Error, "Undefined Unicode code-point."},
+			{Error, "Octal escape sequences are not allowed."},
+			{String, `"ok"`},
+			{UnterminatedString, `'oops`},
+		},
+	},
+	{
+		input: "@",
+		output: []wanted{
+			{Error, `Unexpected character '@'.`},
+		},
+	},
 	{
 		input: "  // comment\nX15",
 		output: []wanted{
@@ -130,3 +200,39 @@ func TestTokenizer(t *testing.T) {
 		checkTestcase(t, &testcases[i])
 	}
 }
+
+func TestErrorPosition(t *testing.T) {
+	tokens := TokenizeString(`let s = "abc\q\x4" + 1;`)
+	if len(tokens) != 7 {
+		t.Fatalf("expected 7 tokens; got %v", tokens)
+	}
+	e := tokens[3]
+	if e.TkType != Error || e.TkLine != 1 || e.TkColumn != 14 {
+		t.Errorf("expected error at the bad escape (1:14); got %v", e)
+	}
+}
+
+func TestUnquote(t *testing.T) {
+	for _, c := range []struct{ literal, value string }{
+		{`"abc"`, "abc"},
+		{`'a\'b'`, "a'b"},
+		{`"\t\x41\u0042\u{43}\0"`, "\tABC\x00"},
+		{`"\uD83D\uDE00"`, "\U0001F600"},
+		{"'one\\\ntwo'", "onetwo"},
+		{`"\a"`, "a"},
+		{`"\u{0000000041}"`, "A"},
+	} {
+		if v, err := Unquote(c.literal); err != nil || v != c.value {
+			t.Errorf("Unquote(%s): expected %q; got %q, %v", c.literal, c.value, v, err)
+		}
+	}
+	if _, err := Unquote(`"\u{}"`); err == nil {
+		t.Errorf("expected an error for an empty code point escape")
+	}
+	if _, err := Unquote(`"\u{00110000}"`); err == nil {
+		t.Errorf("expected an error for a code point above U+10FFFF")
+	}
+	if _, err := Unquote(`"\u{FFFFFFFFFFFFFFFFFFFF}"`); err == nil {
+		t.Errorf("expected an error for a code point with many digits")
+	}
+}
@@ -3,24 +3,88 @@ package scan
 import ()
 
 type Scope struct {
-	def    map[string]*Token
-	parent *Scope
+	def      map[string]*Token
+	declared []*Token // the definitions made in def, in order
+	parent   *Scope
+	function bool // the outermost scope of a function (or of the program)
+
+	// In the scope of a generator `yield` is a keyword, and in the scope of
+	// an async function so is `await`. Both are set on function scopes only.
+	generator bool
+	async     bool
+
+	// pending holds uses of names that were not yet defined when they were
+	// parsed. They are resolved when the scope is popped, because a `var`
+	// declaration may follow its uses.
+	pending []*reference
+
+	// locals holds the declarations of the blocks of a function scope, for
+	// CaptureAnalysis.
+	locals []*Token
+
+	// inner holds the declarations of nested scopes that shadow nothing in
+	// the scopes around them yet, for CheckShadow: a declaration that comes
+	// later in an enclosing scope still encloses them.
+	inner []*Token
 }
 
-func (s *Scope) define(n *Token) {
-	if t, ok := s.def[n.TkValue]; ok {
-		if t.TkReserved {
-			n.Error("Already reserved")
-		} else {
-			n.Error("Already defined")
+type reference struct {
+	name *Token
+	// closure is true once the reference has been carried out of a function
+	// scope; such a use runs later, so any declaration in an outer scope
+	// satisfies it, not only a hoisted `var`.
+	closure bool
+	// declared is true if the name was defined in scope when it was used.
+	declared bool
+	// assigned is true if the name is the target of an assignment.
+	assigned bool
+}
+
+// define adds n to the scope as a declaration of the given kind: "let",
+// "const", "var", "param", "function" or "class". A `var` is defined in every
+// scope up to the enclosing function scope, and may redeclare another `var`,
+// a parameter or the function's own name.
+func (s *Scope) define(n *Token, kind string) {
+	e := s
+	for {
+		if t, ok := e.def[n.TkValue]; ok {
+			if t.TkReserved {
+				n.Error("Already reserved")
+			} else if kind != "var" || (t.NdBinding != "var" &&
+				t.NdBinding != "param" && t.NdBinding != "function") {
+				n.Error("Already defined")
+			}
 		}
+		if kind != "var" || e.function {
+			break
+		}
+		e = e.parent
 	}
-	s.def[n.TkValue] = n
 	n.TkReserved = false
-	n.TkNud = itself
+	n.TkNud = useName
+	n.NdDeclaration = n
 	n.TkLed = nil
 	n.TkStd = nil
 	n.TkLbp = 0
+	n.NdBinding = kind
+	for e = s; ; e = e.parent {
+		if _, ok := e.def[n.TkValue]; !ok {
+			e.def[n.TkValue] = n
+			e.declared = append(e.declared, n)
+		}
+		if kind != "var" || e.function {
+			break
+		}
+	}
+}
+
+// functionScope returns the outermost scope of the function that s belongs to.
+func (s *Scope) functionScope() *Scope {
+	e := s
+	for !e.function {
+		e = e.parent
+	}
+	return e
 }
 
 func (s *Scope) find(name string) *Token {
@@ -49,3 +113,38 @@ func (s *Scope) reserve(n *Token) {
 	s.def[n.TkValue] = n
 	n.TkReserved = true
 }
+
+// assign marks the pending reference to x, a name that has just been parsed,
+// as the target of an assignment.
+func (s *Scope) assign(x *Token) {
+	for i := len(s.pending) - 1; i >= 0; i-- {
+		if s.pending[i].name == x {
+			s.pending[i].assigned = true
+			return
+		}
+	}
+}
+
+// resolve settles the pending references of a scope that is being popped,
+// linking each it can satisfy to its declaration. It returns those it
+// resolved, and those it couldn't, to be carried to the parent scope.
+func (s *Scope) resolve() (resolved, unresolved []*reference) {
+	for _, r := range s.pending {
+		if t, ok := s.def[r.name.TkValue]; ok && !t.TkReserved &&
+			(r.declared || r.closure || t.NdBinding == "var") {
+			if r.assigned && t.NdBinding == "const" {
+				r.name.Error("Assignment to constant.")
+			} else if r.assigned && t.NdBinding == "import" {
+				r.name.Error("Assignment to an imported binding.")
+			}
+			r.name.NdDeclaration = t
+			resolved = append(resolved, r)
+			continue
+		}
+		if s.function {
+			r.closure = true
+		}
+		unresolved = append(unresolved, r)
+	}
+	return resolved, unresolved
+}
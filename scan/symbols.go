@@ -0,0 +1,276 @@
+package scan
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strings"
+)
+
+// A description records what the registration methods know about a symbol
+// that its denotations don't reveal.
+type description struct {
+	associativity string // of an infix operator: "left" or "right"
+	nud, led, std form
+
+	// powers holds every binding power the symbol has been registered with.
+	powers map[int]bool
+}
+
+// A form is what a denotation parses: the arity of the node it builds, if
+// known, and an EBNF-like production for its syntax, in which `…` stands for
+// syntax that a hand-written denotation parses.
+type form struct {
+	arity  string
+	syntax string
+}
+
+// describe returns the description of the symbol id, creating it if need be.
+func (p *Parser) describe(id string) *description {
+	if p.descriptions == nil {
+		p.descriptions = map[string]*description{}
+	}
+	d, ok := p.descriptions[id]
+	if !ok {
+		d = &description{powers: map[int]bool{}}
+		p.descriptions[id] = d
+	}
+	return d
+}
+
+// A SymbolInfo describes a symbol of a Parser's grammar.
+type SymbolInfo struct {
+	Id            string
+	LBP           int  // the left binding power
+	Nud, Led, Std bool // whether the symbol has each kind of denotation
+	Associativity string
+
+	// The arity of the node that each denotation builds, such as "unary",
+	// "binary", "ternary" or "statement", or "" if it isn't known.
+	NudArity, LedArity, StdArity string
+
+	// An EBNF-like production for each denotation, or "" if it isn't known.
+	NudSyntax, LedSyntax, StdSyntax string
+}
+
+// Symbols describes every symbol of p's grammar. They are sorted by
+// descending left binding power, and then by id.
+func (p *Parser) Symbols() []SymbolInfo {
+	infos := []SymbolInfo{}
+	for id, s := range p.symbol_table {
+		info := SymbolInfo{
+			Id:  id,
+			LBP: s.TkLbp,
+			Nud: s.TkNud != nil,
+			Led: s.TkLed != nil,
+			Std: s.TkStd != nil,
+		}
+		if d, ok := p.descriptions[id]; ok {
+			info.Associativity = d.associativity
+			info.NudArity, info.NudSyntax = d.nud.arity, d.nud.syntax
+			info.LedArity, info.LedSyntax = d.led.arity, d.led.syntax
+			info.StdArity, info.StdSyntax = d.std.arity, d.std.syntax
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].LBP != infos[j].LBP {
+			return infos[i].LBP > infos[j].LBP
+		}
+		return infos[i].Id < infos[j].Id
+	})
+	return infos
+}
+
+// WriteDocs writes documentation of p's grammar, generated from its symbol
+// table, to w: a precedence chart of its infix operators, tables of its
+// prefix operators and statements, and a summary of its syntax. The format
+// is "markdown" or "html".
+func (p *Parser) WriteDocs(w io.Writer, format string) error {
+	var d docWriter
+	switch format {
+	case "markdown":
+		d = &markdownDocs{}
+	case "html":
+		d = &htmlDocs{}
+	default:
+		return fmt.Errorf("Unknown documentation format %q.", format)
+	}
+	symbols := p.Symbols()
+
+	d.heading("Operator precedence")
+	d.table([]string{"Binding power", "Operators", "Associativity", "Arity"}, precedenceRows(symbols))
+
+	d.heading("Prefix operators and operands")
+	rows := [][]string{}
+	for _, s := range byId(symbols) {
+		if s.Nud {
+			rows = append(rows, []string{code(s.Id), s.NudArity, code(s.NudSyntax)})
+		}
+	}
+	d.table([]string{"Symbol", "Arity", "Syntax"}, rows)
+
+	d.heading("Statements")
+	rows = [][]string{}
+	for _, s := range byId(symbols) {
+		if s.Std {
+			rows = append(rows, []string{code(s.Id), code(s.StdSyntax)})
+		}
+	}
+	d.table([]string{"Keyword", "Syntax"}, rows)
+
+	d.heading("Grammar summary")
+	d.grammar(grammarSummary(symbols))
+	_, err := io.WriteString(w, d.String())
+	return err
+}
+
+// precedenceRows returns a row of the precedence chart for each group of
+// infix operators that share a binding power, associativity and arity.
+func precedenceRows(symbols []SymbolInfo) [][]string {
+	rows := [][]string{}
+	group := map[[3]string]int{} // the index of each group's row
+	for _, s := range symbols {
+		if !s.Led {
+			continue
+		}
+		key := [3]string{fmt.Sprint(s.LBP), s.Associativity, s.LedArity}
+		if i, ok := group[key]; ok {
+			rows[i][1] += " " + s.Id
+			continue
+		}
+		group[key] = len(rows)
+		rows = append(rows, []string{key[0], s.Id, key[1], key[2]})
+	}
+	for _, row := range rows {
+		row[1] = code(row[1])
+	}
+	return rows
+}
+
+// grammarSummary returns the productions of an EBNF-like summary of the
+// grammar described by symbols.
+func grammarSummary(symbols []SymbolInfo) []string {
+	var operands, operators, statements []string
+	for _, s := range byId(symbols) {
+		if s.NudSyntax != "" {
+			operands = append(operands, s.NudSyntax)
+		}
+		if s.LedSyntax != "" {
+			operators = append(operators, s.LedSyntax)
+		}
+		if s.StdSyntax != "" {
+			statements = append(statements, s.StdSyntax)
+		}
+	}
+	productions := []string{
+		"program ::= { statement }",
+		"statement ::= " + strings.Join(append(statements, `expression ";"`), "\n    | "),
+		`block ::= "{" { statement } "}"`,
+		"expression(rbp) ::= operand { operator }  (* while the operator's lbp > rbp *)",
+	}
+	if len(operands) > 0 {
+		productions = append(productions, "operand ::= "+strings.Join(operands, "\n    | "))
+	}
+	if len(operators) > 0 {
+		productions = append(productions, "operator ::= "+strings.Join(operators, "\n    | "))
+	}
+	return productions
+}
+
+// byId returns a copy of symbols sorted by id.
+func byId(symbols []SymbolInfo) []SymbolInfo {
+	sorted := append([]SymbolInfo(nil), symbols...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Id < sorted[j].Id })
+	return sorted
+}
+
+// code marks s, if it isn't empty, as code; docWriters render it.
+func code(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "\x00" + s
+}
+
+// A docWriter renders the parts of the documentation in some format.
+type docWriter interface {
+	heading(text string)
+	table(header []string, rows [][]string)
+	grammar(productions []string)
+	String() string
+}
+
+type markdownDocs struct {
+	strings.Builder
+}
+
+func (d *markdownDocs) heading(text string) {
+	if d.Len() > 0 {
+		d.WriteString("\n")
+	}
+	fmt.Fprintf(d, "## %s\n\n", text)
+}
+
+func (d *markdownDocs) table(header []string, rows [][]string) {
+	d.row(header)
+	d.WriteString(strings.Repeat("| --- ", len(header)) + "|\n")
+	for _, row := range rows {
+		d.row(row)
+	}
+}
+
+func (d *markdownDocs) row(cells []string) {
+	for _, c := range cells {
+		if strings.HasPrefix(c, "\x00") {
+			c = "`` " + c[1:] + " ``"
+		}
+		d.WriteString("| " + strings.Replace(c, "|", `\|`, -1) + " ")
+	}
+	d.WriteString("|\n")
+}
+
+func (d *markdownDocs) grammar(productions []string) {
+	d.WriteString("```ebnf\n")
+	for _, p := range productions {
+		d.WriteString(p + "\n")
+	}
+	d.WriteString("```\n")
+}
+
+type htmlDocs struct {
+	strings.Builder
+}
+
+func (d *htmlDocs) heading(text string) {
+	fmt.Fprintf(d, "<h2>%s</h2>\n", html.EscapeString(text))
+}
+
+func (d *htmlDocs) table(header []string, rows [][]string) {
+	d.WriteString("<table>\n<tr>")
+	for _, h := range header {
+		fmt.Fprintf(d, "<th>%s</th>", html.EscapeString(h))
+	}
+	d.WriteString("</tr>\n")
+	for _, row := range rows {
+		d.WriteString("<tr>")
+		for _, c := range row {
+			if strings.HasPrefix(c, "\x00") {
+				fmt.Fprintf(d, "<td><code>%s</code></td>", html.EscapeString(c[1:]))
+			} else {
+				fmt.Fprintf(d, "<td>%s</td>", html.EscapeString(c))
+			}
+		}
+		d.WriteString("</tr>\n")
+	}
+	d.WriteString("</table>\n")
+}
+
+func (d *htmlDocs) grammar(productions []string) {
+	d.WriteString("<pre>\n")
+	for _, p := range productions {
+		d.WriteString(html.EscapeString(p) + "\n")
+	}
+	d.WriteString("</pre>\n")
+}
@@ -0,0 +1,86 @@
+package scan
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSymbols(t *testing.T) {
+	symbols := map[string]SymbolInfo{}
+	last := 1 << 30
+	for _, s := range NewParser().Symbols() {
+		if s.LBP > last {
+			t.Errorf("expected symbols sorted by descending lbp; %q (%d) follows %d", s.Id, s.LBP, last)
+		}
+		last = s.LBP
+		symbols[s.Id] = s
+	}
+
+	expect := []SymbolInfo{
+		{Id: "+", LBP: 50, Led: true, Associativity: "left", LedArity: "binary",
+			LedSyntax: `expression "+" expression(50)`},
+		{Id: "&&", LBP: 30, Led: true, Associativity: "right", LedArity: "binary",
+			LedSyntax: `expression "&&" …`},
+		{Id: "=", LBP: 10, Led: true, Associativity: "right", LedArity: "binary",
+			LedSyntax: `target "=" expression(9)`},
+		{Id: "?", LBP: 20, Led: true, Associativity: "left", LedArity: "ternary",
+			LedSyntax: `expression "?" expression ":" expression`},
+		{Id: "?.", LBP: 80, Led: true, Associativity: "left", LedArity: "binary or ternary",
+			LedSyntax: `expression "?." (name | "[" expression "]" | "(" arguments ")")`},
+		{Id: "-", LBP: 50, Nud: true, Led: true, Associativity: "left",
+			NudArity: "unary", NudSyntax: `"-" expression(70)`,
+			LedArity: "binary", LedSyntax: `expression "-" expression(50)`},
+		{Id: "if", LBP: -1, Std: true, StdArity: "statement", StdSyntax: `"if" …`},
+		{Id: "true", LBP: -1, Nud: true, NudArity: "literal", NudSyntax: `"true"`},
+		{Id: ";", LBP: -1},
+	}
+	for _, e := range expect {
+		if s := symbols[e.Id]; s != e {
+			t.Errorf("expected %+v; got %+v", e, s)
+		}
+	}
+}
+
+func TestWriteDocs(t *testing.T) {
+	grammar := `{
+  "infix": {"+": 50, "*": 60},
+  "infixr": {"^": 70},
+  "prefix": ["-"],
+  "groups": {"(": ")"},
+  "statements": {"print": ["expression", "semicolon"]}
+}`
+	parser, err := NewParserFromGrammar(strings.NewReader(grammar))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	if err := parser.WriteDocs(&b, "markdown"); err != nil {
+		t.Fatal(err)
+	}
+	docs := b.String()
+	for _, want := range []string{
+		"| 70 | `` ^ `` | right | binary |\n| 60 | `` * `` | left | binary |\n| 50 | `` + `` | left | binary |\n",
+		"| `` - `` | unary | `` \"-\" expression(70) `` |\n",
+		"| `` print `` | `` \"print\" expression \";\" `` |\n",
+		"operand ::= \"(\" expression \")\"\n    | number | string\n",
+		"    | \"-\" expression(70)\noperator ::= ",
+		"operator ::= expression \"*\" expression(60)\n",
+	} {
+		if !strings.Contains(docs, want) {
+			t.Errorf("expected the docs to contain %q; got\n%s", want, docs)
+		}
+	}
+
+	b.Reset()
+	if err := parser.WriteDocs(&b, "html"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "<tr><td>70</td><td><code>^</code></td><td>right</td><td>binary</td></tr>"; !strings.Contains(b.String(), want) {
+		t.Errorf("expected the HTML docs to contain %q; got\n%s", want, b.String())
+	}
+
+	if err := parser.WriteDocs(&b, "pdf"); err == nil {
+		t.Errorf("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,150 @@
+package scan
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// A TraceKind identifies a step of a parse.
+type TraceKind int
+
+const (
+	TraceExpression TraceKind = iota // expression(rbp) begins
+	TraceReturn                      // expression(rbp) returns
+	TraceNud                         // a token's nud is called
+	TraceLed                         // a token's led is called
+	TraceStd                         // a token's std is called
+	TraceCompare                     // a token's lbp is compared with rbp
+	TraceAdvance                     // the parser moves on to the next token
+)
+
+var traceKindNames = []string{"expression", "return", "nud", "led", "std", "compare", "advance"}
+
+func (k TraceKind) String() string {
+	if k < 0 || int(k) >= len(traceKindNames) {
+		return fmt.Sprintf("TraceKind(%d)", int(k))
+	}
+	return traceKindNames[k]
+}
+
+// A TraceEvent describes a step of a parse. Id, Value, Line and Column
+// describe the token the step concerns: for TraceExpression, the token that
+// begins the expression; for TraceReturn, the node that the expression
+// produced; and for TraceAdvance, the new current token.
+type TraceEvent struct {
+	Kind   TraceKind
+	Depth  int // how many calls of expression are in progress
+	Id     string
+	Value  string
+	Line   int
+	Column int
+	RBP    int  // for TraceExpression, TraceReturn and TraceCompare
+	LBP    int  // for TraceCompare
+	Binds  bool // for TraceCompare: lbp > rbp, so the token's led is called
+}
+
+// TraceHook makes the parser call hook at each step of every parse. A Parser
+// used for several parses at once calls hook from each of them.
+func TraceHook(hook func(TraceEvent)) Option {
+	return func(p *Parser) {
+		p.trace = hook
+	}
+}
+
+// traceStep reports a step concerning the token t to the trace hook, if any.
+func (p *Parser) traceStep(kind TraceKind, t *Token, rbp int) {
+	if p.trace == nil {
+		return
+	}
+	e := TraceEvent{Kind: kind, Depth: p.depth, RBP: rbp}
+	if t != nil {
+		e.Id, e.Value, e.Line, e.Column = t.NdId, t.TkValue, t.TkLine, t.TkColumn
+		if kind == TraceCompare {
+			e.LBP = t.TkLbp
+			e.Binds = rbp < t.TkLbp
+		}
+	}
+	p.trace(e)
+}
+
+// A Trace records the steps of a parse. Pass its Record method to TraceHook.
+type Trace struct {
+	Events []TraceEvent
+}
+
+// Record adds e to t.
+func (t *Trace) Record(e TraceEvent) {
+	t.Events = append(t.Events, e)
+}
+
+// describe returns a one-line description of e.
+func (e TraceEvent) describe() string {
+	token := fmt.Sprintf("`%s`", e.Value)
+	if e.Id != "" && e.Id != e.Value {
+		token += fmt.Sprintf(" (%s)", e.Id)
+	}
+	if e.Kind != TraceReturn {
+		token += fmt.Sprintf(" at %d:%d", e.Line, e.Column)
+	}
+	switch e.Kind {
+	case TraceExpression:
+		return fmt.Sprintf("expression(%d) begins with %s", e.RBP, token)
+	case TraceReturn:
+		return fmt.Sprintf("expression(%d) returns %s", e.RBP, token)
+	case TraceCompare:
+		verdict := "stop"
+		if e.Binds {
+			verdict = "continue"
+		}
+		return fmt.Sprintf("compare %s: lbp %d > rbp %d? %s", token, e.LBP, e.RBP, verdict)
+	case TraceAdvance:
+		return fmt.Sprintf("advance to %s", token)
+	}
+	return fmt.Sprintf("%s of %s", e.Kind, token)
+}
+
+// WriteText writes the trace to w as text, one step per line, indented by
+// the depth of the expression the step belongs to.
+func (t *Trace) WriteText(w io.Writer) error {
+	for _, e := range t.Events {
+		if _, err := fmt.Fprintf(w, "%s%s\n", strings.Repeat("  ", e.Depth), e.describe()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteHTML writes the trace to w as an HTML page that shows the steps as a
+// timeline, indented by depth and colored by kind.
+func (t *Trace) WriteHTML(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Parse trace</title>
+<style>
+body { font-family: monospace; }
+ol { list-style: none; padding: 0; }
+li { padding: 1px 4px; border-left: 3px solid #ccc; }
+li.expression { border-color: #36c; font-weight: bold; }
+li.return { border-color: #36c; color: #36c; }
+li.nud, li.led, li.std { border-color: #3a3; }
+li.compare { border-color: #c90; color: #666; }
+li.advance { border-color: #999; color: #999; }
+.step { display: inline-block; width: 4em; color: #999; }
+</style>
+</head>
+<body>
+<ol>
+`)
+	for i, e := range t.Events {
+		fmt.Fprintf(&b, "<li class=\"%s\" style=\"margin-left: %dem\"><span class=\"step\">%d</span>%s</li>\n",
+			e.Kind, 2*e.Depth, i+1, html.EscapeString(e.describe()))
+	}
+	b.WriteString("</ol>\n</body>\n</html>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
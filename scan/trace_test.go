@@ -0,0 +1,94 @@
+package scan
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	defer recoverFromPanic(t)
+	var trace Trace
+	parser := NewParser(TraceHook(trace.Record))
+	parser.ParseString(`let a; a = 1 + 2 * 3;`)
+
+	var text strings.Builder
+	if err := trace.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	expected := "advance to `let` at 1:0\n" +
+		"std of `let` at 1:0\n" +
+		"advance to `a` ((name)) at 1:4\n" +
+		"advance to `;` at 1:5\n" +
+		"advance to `a` ((name)) at 1:7\n" +
+		"  expression(0) begins with `a` ((name)) at 1:7\n" +
+		"  advance to `=` at 1:9\n" +
+		"  nud of `a` ((name)) at 1:7\n" +
+		"  compare `=` at 1:9: lbp 10 > rbp 0? continue\n" +
+		"  led of `=` at 1:9\n" +
+		"  advance to `1` ((literal)) at 1:11\n" +
+		"    expression(9) begins with `1` ((literal)) at 1:11\n" +
+		"    advance to `+` at 1:13\n" +
+		"    nud of `1` ((literal)) at 1:11\n" +
+		"    compare `+` at 1:13: lbp 50 > rbp 9? continue\n" +
+		"    led of `+` at 1:13\n" +
+		"    advance to `2` ((literal)) at 1:15\n" +
+		"      expression(50) begins with `2` ((literal)) at 1:15\n" +
+		"      advance to `*` at 1:17\n" +
+		"      nud of `2` ((literal)) at 1:15\n" +
+		"      compare `*` at 1:17: lbp 60 > rbp 50? continue\n" +
+		"      led of `*` at 1:17\n" +
+		"      advance to `3` ((literal)) at 1:19\n" +
+		"        expression(60) begins with `3` ((literal)) at 1:19\n" +
+		"        advance to `;` at 1:20\n" +
+		"        nud of `3` ((literal)) at 1:19\n" +
+		"        compare `;` at 1:20: lbp -1 > rbp 60? stop\n" +
+		"        expression(60) returns `3` ((literal))\n" +
+		"      compare `;` at 1:20: lbp -1 > rbp 50? stop\n" +
+		"      expression(50) returns `*`\n" +
+		"    compare `;` at 1:20: lbp -1 > rbp 9? stop\n" +
+		"    expression(9) returns `+`\n" +
+		"  compare `;` at 1:20: lbp -1 > rbp 0? stop\n" +
+		"  expression(0) returns `=`\n" +
+		"advance to `(end)` at 1:21\n" +
+		"advance to `(end)` at 1:21\n"
+	if got := text.String(); got != expected {
+		t.Errorf("expected the trace\n%s\ngot\n%s", expected, got)
+	}
+
+	var page strings.Builder
+	if err := trace.WriteHTML(&page); err != nil {
+		t.Fatal(err)
+	}
+	if got := page.String(); !strings.Contains(got, `<li class="compare" style="margin-left: 4em">`) ||
+		!strings.Contains(got, "lbp 60 &gt; rbp 50? continue") {
+		t.Errorf("expected an HTML timeline; got\n%s", got)
+	}
+
+	// The parameter of an arrow function is not parsed by a nud.
+	trace = Trace{}
+	NewParser(TraceHook(trace.Record)).ParseString(`let f = x => x;`)
+	text.Reset()
+	if err := trace.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	expected = "advance to `let` at 1:0\n" +
+		"std of `let` at 1:0\n" +
+		"advance to `f` ((name)) at 1:4\n" +
+		"advance to `=` at 1:6\n" +
+		"advance to `x` ((name)) at 1:8\n" +
+		"  expression(0) begins with `x` ((name)) at 1:8\n" +
+		"  advance to `=>` at 1:10\n" +
+		"  advance to `x` ((name)) at 1:13\n" +
+		"    expression(9) begins with `x` ((name)) at 1:13\n" +
+		"    advance to `;` at 1:14\n" +
+		"    nud of `x` ((name)) at 1:13\n" +
+		"    compare `;` at 1:14: lbp -1 > rbp 9? stop\n" +
+		"    expression(9) returns `x` ((name))\n" +
+		"  compare `;` at 1:14: lbp -1 > rbp 0? stop\n" +
+		"  expression(0) returns `=>`\n" +
+		"advance to `(end)` at 1:15\n" +
+		"advance to `(end)` at 1:15\n"
+	if got := text.String(); got != expected {
+		t.Errorf("expected the trace\n%s\ngot\n%s", expected, got)
+	}
+}
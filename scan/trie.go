@@ -0,0 +1,63 @@
+package scan
+
+// A trie recognizes the longest of a set of punctuators at the start of a
+// string.
+type trie struct {
+	children map[byte]*trie
+	terminal bool // the path to this node spells a punctuator
+}
+
+func newTrie(words []string) *trie {
+	root := &trie{}
+	for _, w := range words {
+		root.insert(w)
+	}
+	return root
+}
+
+func (t *trie) insert(word string) {
+	for i := 0; i < len(word); i++ {
+		if t.children == nil {
+			t.children = map[byte]*trie{}
+		}
+		next, ok := t.children[word[i]]
+		if !ok {
+			next = &trie{}
+			t.children[word[i]] = next
+		}
+		t = next
+	}
+	t.terminal = true
+}
+
+// longest returns the length of the longest punctuator that s begins with,
+// or 0 if it begins with none.
+func (t *trie) longest(s string) int {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		next, ok := t.children[s[i]]
+		if !ok {
+			break
+		}
+		t = next
+		if t.terminal {
+			n = i + 1
+		}
+	}
+	return n
+}
+
+// isPunctuator reports whether the symbol id is spelled with punctuation
+// characters only, and so is lexed as a Punctuator token rather than a Name.
+func isPunctuator(id string) bool {
+	if id == "" {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if isNameByte(c) || c <= ' ' || c >= 0x7f || c == '"' || c == '\'' || c == '`' {
+			return false
+		}
+	}
+	return true
+}
@@ -4,9 +4,9 @@ package scan
 
 import "strconv"
 
-const _Type_name = "UnknownErrorNamePunctuatorFixnumFlonumStringUnterminatedStringLiteralnameArityliteralAritythisArityfunctionArityunaryAritybinaryArityternaryAritystatementAritylistArity"
+const _Type_name = "UnknownErrorNamePunctuatorFixnumFlonumStringUnterminatedStringTemplateTemplateHeadRegexpCustomLiteralnameArityliteralAritythisArityfunctionArityunaryAritybinaryArityternaryAritystatementAritylistAritypatternArityclassAritysuperAritypropertyAritytemplateArityholeArity"
 
-var _Type_index = [...]uint8{0, 7, 12, 16, 26, 32, 38, 44, 62, 69, 78, 90, 99, 112, 122, 133, 145, 159, 168}
+var _Type_index = [...]uint16{0, 7, 12, 16, 26, 32, 38, 44, 62, 70, 82, 88, 94, 101, 110, 122, 131, 144, 154, 165, 177, 191, 200, 212, 222, 232, 245, 258, 267}
 
 func (i Type) String() string {
 	if i < 0 || i >= Type(len(_Type_index)-1) {
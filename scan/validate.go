@@ -0,0 +1,154 @@
+package scan
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A GrammarProblem is a kind of suspicious configuration that Validate
+// reports.
+type GrammarProblem string
+
+const (
+	// ProblemPowers reports a symbol registered with more than one binding
+	// power, which silently gets the highest of them.
+	ProblemPowers GrammarProblem = "powers"
+	// ProblemLostDenotation reports a symbol that was registered as an
+	// operator or statement but has lost the denotation that makes it one.
+	ProblemLostDenotation GrammarProblem = "lost-denotation"
+	// ProblemNoLed reports a symbol with a binding power but no led, which
+	// ends any expression it follows but can't continue it.
+	ProblemNoLed GrammarProblem = "no-led"
+	// ProblemNudAndStd reports a symbol with both a nud and a std, whose nud
+	// is never used at the start of a statement.
+	ProblemNudAndStd GrammarProblem = "nud-and-std"
+	// ProblemAssociativity reports infix operators of both associativities
+	// with the same binding power, which group differently depending on
+	// which comes first.
+	ProblemAssociativity GrammarProblem = "associativity"
+	// ProblemArity reports infix operators that build nodes of different
+	// arities with the same binding power: unrelated operators whose binding
+	// powers overlap, probably by accident.
+	ProblemArity GrammarProblem = "arity"
+)
+
+// A GrammarWarning reports a suspicious configuration of a grammar's symbols:
+// one that is legal, but that may not do what the grammar's author meant.
+type GrammarWarning struct {
+	Problem GrammarProblem
+	Symbols []string // the symbols involved, sorted
+	Message string
+}
+
+func (w GrammarWarning) String() string {
+	return fmt.Sprintf("%s: %s (%s)", strings.Join(w.Symbols, " "), w.Message, w.Problem)
+}
+
+// Allow marks a configuration as intended: Validate doesn't report problem
+// about exactly the symbols ids. A warning about more symbols, such as an
+// operator added at a binding power whose operators were allowed to mix
+// associativities, is still reported.
+func (p *Parser) Allow(problem GrammarProblem, ids ...string) {
+	p.allow(problem, ids...)
+}
+
+func (p *Parser) allow(problem GrammarProblem, ids ...string) {
+	if p.allowed == nil {
+		p.allowed = map[string]bool{}
+	}
+	p.allowed[allowedKey(problem, ids)] = true
+}
+
+func allowedKey(problem GrammarProblem, ids []string) string {
+	ids = append([]string(nil), ids...)
+	sort.Strings(ids)
+	return string(problem) + ":" + strings.Join(ids, " ")
+}
+
+// Validate checks the symbol table of p for the suspicious configurations
+// described by the GrammarProblem constants, except those marked as intended
+// with Allow. The warnings are sorted by their symbols.
+func (p *Parser) Validate() []GrammarWarning {
+	warnings := []GrammarWarning{}
+	warn := func(problem GrammarProblem, message string, ids ...string) {
+		if p.allowed[allowedKey(problem, ids)] {
+			return
+		}
+		sort.Strings(ids)
+		warnings = append(warnings, GrammarWarning{Problem: problem, Symbols: ids, Message: message})
+	}
+
+	// Infix operators at each binding power, by associativity and by arity.
+	levels := map[int]map[string][]string{}
+	arities := map[int]map[string][]string{}
+	for id, s := range p.symbol_table {
+		d, ok := p.descriptions[id]
+		if !ok {
+			d = &description{}
+		}
+		if len(d.powers) > 1 {
+			powers := []int{}
+			for bp := range d.powers {
+				powers = append(powers, bp)
+			}
+			sort.Ints(powers)
+			warn(ProblemPowers, fmt.Sprintf("registered with binding powers %s; it has %d.",
+				strings.Trim(fmt.Sprint(powers), "[]"), s.TkLbp), id)
+		}
+		if d.nud.syntax != "" && s.TkNud == nil {
+			warn(ProblemLostDenotation, "registered as a prefix operator or operand, but has no nud.", id)
+		}
+		if d.led.syntax != "" && s.TkLed == nil {
+			warn(ProblemLostDenotation, "registered as an infix operator, but has no led.", id)
+		}
+		if d.std.syntax != "" && s.TkStd == nil {
+			warn(ProblemLostDenotation, "registered as a statement, but has no std.", id)
+		}
+		if s.TkLbp > 0 && s.TkLed == nil {
+			warn(ProblemNoLed, fmt.Sprintf("has binding power %d, but no led.", s.TkLbp), id)
+		}
+		if s.TkNud != nil && s.TkStd != nil {
+			warn(ProblemNudAndStd, "has both a nud and a std; the std is used at the start of a statement.", id)
+		}
+		if s.TkLed != nil && d.associativity != "" {
+			if levels[s.TkLbp] == nil {
+				levels[s.TkLbp] = map[string][]string{}
+			}
+			levels[s.TkLbp][d.associativity] = append(levels[s.TkLbp][d.associativity], id)
+		}
+		if s.TkLed != nil && d.led.arity != "" {
+			if arities[s.TkLbp] == nil {
+				arities[s.TkLbp] = map[string][]string{}
+			}
+			arities[s.TkLbp][d.led.arity] = append(arities[s.TkLbp][d.led.arity], id)
+		}
+	}
+	for bp, level := range levels {
+		if len(level["left"]) > 0 && len(level["right"]) > 0 {
+			sort.Strings(level["left"])
+			sort.Strings(level["right"])
+			warn(ProblemAssociativity, fmt.Sprintf("mix left-associative %s and right-associative %s at binding power %d.",
+				strings.Join(level["left"], " "), strings.Join(level["right"], " "), bp),
+				append(level["left"], level["right"]...)...)
+		}
+	}
+	for bp, level := range arities {
+		if len(level) < 2 {
+			continue
+		}
+		kinds, ids := []string{}, []string{}
+		for arity, symbols := range level {
+			sort.Strings(symbols)
+			kinds = append(kinds, fmt.Sprintf("%s %s", strings.Join(symbols, " "), arity))
+			ids = append(ids, symbols...)
+		}
+		sort.Strings(kinds)
+		warn(ProblemArity, fmt.Sprintf("build nodes of different arities at binding power %d: %s.",
+			bp, strings.Join(kinds, "; ")), ids...)
+	}
+	sort.Slice(warnings, func(i, j int) bool {
+		return warnings[i].String() < warnings[j].String()
+	})
+	return warnings
+}
@@ -0,0 +1,77 @@
+package scan
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	if w := NewParser().Validate(); len(w) != 0 {
+		t.Errorf("expected the JavaScript grammar to validate; got %v", w)
+	}
+
+	parser := NewParser()
+	parser.Infix("mod", 60, nil)
+	parser.Infix("mod", 65, nil)
+	parser.Prefix("not", nil).TkNud = nil
+	parser.Symbol("..", 45)
+	parser.Infixr("**", 60, nil)
+	parser.Infix("?:", 20, nil)
+	parser.Infixr("?=", 30, nil)
+	parser.Infix("<>", 40, nil)
+	parser.Allow(ProblemAssociativity, "<>", "!==", "<", "<=", "===", ">", ">=")
+
+	got := []string{}
+	for _, w := range parser.Validate() {
+		got = append(got, w.String())
+	}
+	expect := []string{
+		"&& ?= ?? ||: mix left-associative ?? and right-associative && ?= || at binding power 30. (associativity)",
+		"* ** /: mix left-associative * / and right-associative ** at binding power 60. (associativity)",
+		"..: has binding power 45, but no led. (no-led)",
+		"? ?:: build nodes of different arities at binding power 20: ? ternary; ?: binary. (arity)",
+		"mod: registered with binding powers 60 65; it has 65. (powers)",
+		"not: registered as a prefix operator or operand, but has no nud. (lost-denotation)",
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("expected warnings\n%s\ngot\n%s", strings.Join(expect, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestValidateCleanGrammar(t *testing.T) {
+	grammar := `{
+  "infix": {"+": 50, "-": 50, "*": 60},
+  "infixr": {"^": 70},
+  "prefix": ["-"],
+  "groups": {"(": ")"}
+}`
+	parser, err := NewParserFromGrammar(strings.NewReader(grammar))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := parser.Validate(); len(w) != 0 {
+		t.Errorf("expected no warnings; got %v", w)
+	}
+}
+
+func TestValidateAllowedGrammar(t *testing.T) {
+	grammar := `{
+  "infix": {"+": 50},
+  "infixr": {"^": 50},
+  "allow": [{"problem": "associativity", "symbols": ["^", "+"]}]
+}`
+	parser, err := NewParserFromGrammar(strings.NewReader(grammar))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := parser.Validate(); len(w) != 0 {
+		t.Errorf("expected no warnings; got %v", w)
+	}
+
+	grammar = `{"allow": [{"problem": "style", "symbols": ["+"]}]}`
+	if _, err := NewParserFromGrammar(strings.NewReader(grammar)); err == nil ||
+		!strings.Contains(err.Error(), `unknown problem "style"`) {
+		t.Errorf("expected an unknown problem; got %v", err)
+	}
+}
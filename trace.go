@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/perlmonger42/tdop/scan"
+)
+
+// trace implements `tdop trace [-html] [-asi] file`, which prints the steps
+// of the parse of a JavaScript file, as indented text or as an HTML
+// timeline. It returns the exit status.
+func trace(args []string) (status int) {
+	flags := flag.NewFlagSet("trace", flag.ContinueOnError)
+	asHTML := flags.Bool("html", false, "write an HTML timeline")
+	asi := flags.Bool("asi", false, "insert semicolons automatically")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: tdop trace [-html] [-asi] file")
+		return 2
+	}
+	source, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	var t scan.Trace
+	options := []scan.Option{scan.TraceHook(t.Record)}
+	if *asi {
+		options = append(options, scan.AutomaticSemicolons())
+	}
+	defer func() {
+		// The trace of a parse that fails shows how it got there.
+		if r := recover(); r != nil {
+			fmt.Fprintln(os.Stderr, r)
+			status = 1
+		}
+		if *asHTML {
+			err = t.WriteHTML(os.Stdout)
+		} else {
+			err = t.WriteText(os.Stdout)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+		}
+	}()
+	scan.NewParser(options...).ParseString(string(source))
+	return 0
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/perlmonger42/tdop/scan"
+)
+
+// validate implements `tdop validate [-grammar file]`, which reports
+// suspicious configurations of the JavaScript grammar, or of the grammar
+// defined by a JSON file, one per line. It returns the exit status: 1 if
+// there are any.
+func validate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	grammar := flags.String("grammar", "", "validate the grammar defined by this JSON file")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, "usage: tdop validate [-grammar file]")
+		return 2
+	}
+
+	parser := scan.NewParser()
+	if *grammar != "" {
+		f, err := os.Open(*grammar)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer f.Close()
+		if parser, err = scan.NewParserFromGrammar(f); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	warnings := parser.Validate()
+	for _, w := range warnings {
+		fmt.Println(w)
+	}
+	if len(warnings) > 0 {
+		return 1
+	}
+	return 0
+}